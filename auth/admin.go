@@ -0,0 +1,20 @@
+package auth
+
+import (
+	"os"
+	"strings"
+)
+
+// IsAdmin reports whether the given email belongs to a Benevolent Bites administrator.
+// Administrators are listed in the comma separated S_ADMINS environment variable
+func IsAdmin(email string) bool {
+	if email == "" {
+		return false
+	}
+	for _, a := range strings.Split(os.Getenv("S_ADMINS"), ",") {
+		if strings.EqualFold(strings.TrimSpace(a), email) {
+			return true
+		}
+	}
+	return false
+}
@@ -16,6 +16,9 @@ import (
 
 var (
 	Conf *oauth2.Config
+
+	// TokenInfoURL is where ValidateToken checks tokens, tests point it at their own server
+	TokenInfoURL = "https://oauth2.googleapis.com/tokeninfo"
 )
 
 // Initialize creates the OAuth2.0 client
@@ -47,7 +50,7 @@ func GetTokenFromOAuthCode(code string) *oauth2.Token {
 
 // ValidateToken verifies that a token is valid through Google's API
 func ValidateToken(t string) (map[string]interface{}, error) {
-	resp, err := http.Get(fmt.Sprintf("https://oauth2.googleapis.com/tokeninfo?id_token=%s", t))
+	resp, err := http.Get(fmt.Sprintf("%s?id_token=%s", TokenInfoURL, t))
 	if err != nil {
 		return nil, errors.New("sorry bro, unable to verify your token")
 	}
@@ -0,0 +1,146 @@
+package auth
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"time"
+)
+
+// RefundOrder refunds part or all of the payment made for a Square order.
+// It returns the ID of the refund which Square created. Square only refunds once
+// for each idempotency key, so retrying with the same key is safe.
+func RefundOrder(s *SquareAuth, orderID string, amount int, reason, idempotencyKey string) (string, error) {
+	if orderID == "" {
+		return "", errors.New("no square order recorded for this card")
+	}
+
+	err := RefreshAccessToken(s)
+	if err != nil {
+		return "", err
+	}
+
+	paymentID, err := getOrderPaymentID(s, orderID)
+	if err != nil {
+		return "", err
+	}
+
+	requestData, err := json.Marshal(map[string]interface{}{
+		"idempotency_key": idempotencyKey,
+		"payment_id":      paymentID,
+		"reason":          reason,
+		"amount_money": map[string]interface{}{
+			"amount":   amount,
+			"currency": "USD",
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	body, err := sendSquareRequest(s, "POST", "/v2/refunds", requestData)
+	if err != nil {
+		return "", err
+	}
+
+	var response struct {
+		Refund struct {
+			ID     string `json:"id"`
+			Status string `json:"status"`
+		} `json:"refund"`
+	}
+	err = json.Unmarshal(body, &response)
+	if err != nil {
+		return "", fmt.Errorf("Error unmarshaling refund data: %s", err.Error())
+	}
+	if response.Refund.Status == "REJECTED" || response.Refund.Status == "FAILED" {
+		return "", fmt.Errorf("square refund %s was %s", response.Refund.ID, response.Refund.Status)
+	}
+
+	return response.Refund.ID, nil
+}
+
+// getOrderPaymentID finds the payment which paid for a Square order
+func getOrderPaymentID(s *SquareAuth, orderID string) (string, error) {
+	requestData, err := json.Marshal(map[string]interface{}{
+		"location_id": s.LocationID,
+		"order_ids":   []string{orderID},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	body, err := sendSquareRequest(s, "POST", "/v2/orders/batch-retrieve", requestData)
+	if err != nil {
+		return "", err
+	}
+
+	var response struct {
+		Orders []struct {
+			Tenders []struct {
+				ID        string `json:"id"`
+				PaymentID string `json:"payment_id"`
+			} `json:"tenders"`
+		} `json:"orders"`
+	}
+	err = json.Unmarshal(body, &response)
+	if err != nil {
+		return "", fmt.Errorf("Error unmarshaling order data: %s", err.Error())
+	}
+
+	if len(response.Orders) == 0 || len(response.Orders[0].Tenders) == 0 {
+		return "", fmt.Errorf("unable to find a payment for square order %s", orderID)
+	}
+
+	tender := response.Orders[0].Tenders[0]
+	if tender.PaymentID != "" {
+		return tender.PaymentID, nil
+	}
+	return tender.ID, nil
+}
+
+// sendSquareRequest sends an authenticated request to the Square API, and
+// turns any errors in the response into a Go error
+func sendSquareRequest(s *SquareAuth, method, path string, requestData []byte) ([]byte, error) {
+	request, err := http.NewRequest(method, fmt.Sprintf("%s%s", os.Getenv("SQ_URL"), path), bytes.NewBuffer(requestData))
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("Authorization", fmt.Sprintf("Bearer %s", s.AccessToken))
+
+	timeout := time.Duration(5 * time.Second)
+	client := http.Client{
+		Timeout: timeout,
+	}
+
+	resp, err := client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode >= 300 {
+		var response struct {
+			Errors []struct {
+				Code   string `json:"code"`
+				Detail string `json:"detail"`
+			} `json:"errors"`
+		}
+		if err := json.Unmarshal(body, &response); err == nil && len(response.Errors) > 0 {
+			return nil, fmt.Errorf("square error %s: %s", response.Errors[0].Code, response.Errors[0].Detail)
+		}
+		return nil, fmt.Errorf("square returned status %d", resp.StatusCode)
+	}
+
+	return body, nil
+}
@@ -88,6 +88,9 @@ type Checkout struct {
 	ID        string `json:"id"`
 	URL       string `json:"checkout_page_url"`
 	Timestamp string `json:"created_at"`
+	Order     struct {
+		ID string `json:"id"`
+	} `json:"order"`
 	Amount    int
 	RestID    string
 	UserEmail string
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rishabh-bector/BenevolentBitesBack/auth"
+	"github.com/rishabh-bector/BenevolentBitesBack/database"
+	"github.com/rishabh-bector/BenevolentBitesBack/places"
+
+	log "github.com/sirupsen/logrus"
+)
+
+type SplitData struct {
+	RestID     string  `json:"restId"`
+	Employees  float64 `json:"employees"`
+	Restaurant float64 `json:"restaurant"`
+	Platform   float64 `json:"platform"`
+	Effective  string  `json:"effective"` // RFC3339 or YYYY-MM-DD, defaults to now
+}
+
+// SetRestaurantSplit allows an admin to change how a restaurant's sales are divided
+func SetRestaurantSplit(c *gin.Context) {
+	// Obtain and validate google token
+	token, err := c.Cookie("bb-access")
+	if err != nil {
+		log.Error(err)
+		c.JSON(403, gin.H{"error": "sorry bro, unable to find cookie token"})
+		return
+	}
+
+	verify, err := auth.ValidateToken(token)
+	if err != nil {
+		log.Error(err)
+		c.JSON(403, gin.H{"error": err.Error()})
+		return
+	}
+	email := verify["email"].(string)
+
+	if !auth.IsAdmin(email) {
+		c.JSON(403, gin.H{"error": "sorry bro, only admins can do that"})
+		return
+	}
+
+	var data SplitData
+	if err := c.ShouldBindJSON(&data); err != nil {
+		c.JSON(403, gin.H{"error": "sorry bro, invalid json"})
+		return
+	}
+
+	effective, err := parseEffectiveDate(data.Effective)
+	if err != nil {
+		c.JSON(403, gin.H{"error": "sorry bro, invalid effective date"})
+		return
+	}
+
+	policy := database.SplitPolicy{
+		Employees:  data.Employees,
+		Restaurant: data.Restaurant,
+		Platform:   data.Platform,
+		Effective:  effective,
+		SetBy:      email,
+	}
+
+	err = database.AddRestaurantSplit(data.RestID, policy)
+	if err != nil {
+		c.JSON(403, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(200, gin.H{})
+}
+
+// GetRestaurantSplits returns every split policy a restaurant has had, and the one in effect now
+func GetRestaurantSplits(c *gin.Context) {
+	// Obtain and validate google token
+	token, err := c.Cookie("bb-access")
+	if err != nil {
+		log.Error(err)
+		c.JSON(403, gin.H{"error": "sorry bro, unable to find cookie token"})
+		return
+	}
+
+	verify, err := auth.ValidateToken(token)
+	if err != nil {
+		log.Error(err)
+		c.JSON(403, gin.H{"error": err.Error()})
+		return
+	}
+	email := verify["email"].(string)
+
+	if !auth.IsAdmin(email) {
+		c.JSON(403, gin.H{"error": "sorry bro, only admins can do that"})
+		return
+	}
+
+	r := database.DoesRestaurantExistUUID(c.Query("restId"))
+	if r.Owner == "nil" {
+		c.JSON(403, gin.H{"error": "sorry bro, that restaurant doesn't exist"})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"current": r.SplitAt(time.Now()),
+		"history": r.Splits,
+	})
+}
+
+type CapsData struct {
+	RestID         string `json:"restId"`
+	MaxOutstanding int    `json:"maxOutstanding"` // cents, 0 for no cap
+	MaxCardBalance int    `json:"maxCardBalance"` // cents, 0 for no cap
+}
+
+// SetRestaurantCaps allows an admin to limit how much credit a restaurant may have outstanding
+func SetRestaurantCaps(c *gin.Context) {
+	// Obtain and validate google token
+	token, err := c.Cookie("bb-access")
+	if err != nil {
+		log.Error(err)
+		c.JSON(403, gin.H{"error": "sorry bro, unable to find cookie token"})
+		return
+	}
+
+	verify, err := auth.ValidateToken(token)
+	if err != nil {
+		log.Error(err)
+		c.JSON(403, gin.H{"error": err.Error()})
+		return
+	}
+	email := verify["email"].(string)
+
+	if !auth.IsAdmin(email) {
+		c.JSON(403, gin.H{"error": "sorry bro, only admins can do that"})
+		return
+	}
+
+	var data CapsData
+	if err := c.ShouldBindJSON(&data); err != nil {
+		c.JSON(403, gin.H{"error": "sorry bro, invalid json"})
+		return
+	}
+
+	err = database.SetRestaurantCaps(data.RestID, email, data.MaxOutstanding, data.MaxCardBalance)
+	if err != nil {
+		c.JSON(403, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(200, gin.H{})
+}
+
+// GetPlacesCacheStats returns how often the Google Places cache has been hit since startup
+func GetPlacesCacheStats(c *gin.Context) {
+	// Obtain and validate google token
+	token, err := c.Cookie("bb-access")
+	if err != nil {
+		log.Error(err)
+		c.JSON(403, gin.H{"error": "sorry bro, unable to find cookie token"})
+		return
+	}
+
+	verify, err := auth.ValidateToken(token)
+	if err != nil {
+		log.Error(err)
+		c.JSON(403, gin.H{"error": err.Error()})
+		return
+	}
+	email := verify["email"].(string)
+
+	if !auth.IsAdmin(email) {
+		c.JSON(403, gin.H{"error": "sorry bro, only admins can do that"})
+		return
+	}
+
+	stats, ok := places.GetCacheStats()
+	if !ok {
+		c.JSON(200, gin.H{"enabled": false})
+		return
+	}
+
+	entries, err := database.CountPlacesCache()
+	if err != nil {
+		log.Error(err)
+	}
+
+	c.JSON(200, gin.H{"enabled": true, "entries": entries, "stats": stats})
+}
+
+// GetDuplicatePlaces lists places claimed by more than one restaurant, which have to be
+// fixed before place IDs can be kept unique
+func GetDuplicatePlaces(c *gin.Context) {
+	// Obtain and validate google token
+	token, err := c.Cookie("bb-access")
+	if err != nil {
+		log.Error(err)
+		c.JSON(403, gin.H{"error": "sorry bro, unable to find cookie token"})
+		return
+	}
+
+	verify, err := auth.ValidateToken(token)
+	if err != nil {
+		log.Error(err)
+		c.JSON(403, gin.H{"error": err.Error()})
+		return
+	}
+	email := verify["email"].(string)
+
+	if !auth.IsAdmin(email) {
+		c.JSON(403, gin.H{"error": "sorry bro, only admins can do that"})
+		return
+	}
+
+	dups, err := database.FindDuplicatePlaceIDs()
+	if err != nil {
+		log.Error(err)
+		c.JSON(403, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(200, dups)
+}
+
+func parseEffectiveDate(s string) (time.Time, error) {
+	if s == "" {
+		return time.Now(), nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", s)
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/rishabh-bector/BenevolentBitesBack/auth"
+	"github.com/rishabh-bector/BenevolentBitesBack/database"
+
+	log "github.com/sirupsen/logrus"
+)
+
+type CampaignData struct {
+	ID          string `json:"id"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Goal        int    `json:"goal"`  // Cents
+	Start       string `json:"start"` // RFC3339 or YYYY-MM-DD, defaults to now
+	End         string `json:"end"`   // RFC3339 or YYYY-MM-DD, optional
+}
+
+// StartCampaign allows a restaurant owner to start a fundraising campaign
+func StartCampaign(c *gin.Context) {
+	// Obtain and validate google token
+	token, err := c.Cookie("bb-access")
+	if err != nil {
+		log.Error(err)
+		c.JSON(403, gin.H{"error": "sorry bro, unable to find cookie token"})
+		return
+	}
+
+	verify, err := auth.ValidateToken(token)
+	if err != nil {
+		log.Error(err)
+		c.JSON(403, gin.H{"error": err.Error()})
+		return
+	}
+	email := verify["email"].(string)
+
+	var data CampaignData
+	if err := c.ShouldBindJSON(&data); err != nil {
+		c.JSON(403, gin.H{"error": "sorry bro, invalid json"})
+		return
+	}
+
+	start, err := parseEffectiveDate(data.Start)
+	if err != nil {
+		c.JSON(403, gin.H{"error": "sorry bro, invalid start date"})
+		return
+	}
+
+	campaign := database.Campaign{
+		Title:       data.Title,
+		Description: data.Description,
+		Goal:        data.Goal,
+		Start:       start,
+	}
+
+	if data.End != "" {
+		end, err := parseEffectiveDate(data.End)
+		if err != nil {
+			c.JSON(403, gin.H{"error": "sorry bro, invalid end date"})
+			return
+		}
+		campaign.End = &end
+	}
+
+	campaign, err = database.AddRestaurantCampaign(email, campaign)
+	if err != nil {
+		c.JSON(403, gin.H{"error": err.Error()})
+		return
+	}
+
+	go notifyFollowers(email, campaignMessage(campaign))
+
+	c.JSON(200, campaign)
+}
+
+// EndCampaign allows a restaurant owner to end their campaign early
+func EndCampaign(c *gin.Context) {
+	// Obtain and validate google token
+	token, err := c.Cookie("bb-access")
+	if err != nil {
+		log.Error(err)
+		c.JSON(403, gin.H{"error": "sorry bro, unable to find cookie token"})
+		return
+	}
+
+	verify, err := auth.ValidateToken(token)
+	if err != nil {
+		log.Error(err)
+		c.JSON(403, gin.H{"error": err.Error()})
+		return
+	}
+	email := verify["email"].(string)
+
+	var data CampaignData
+	if err := c.ShouldBindJSON(&data); err != nil {
+		c.JSON(403, gin.H{"error": "sorry bro, invalid json"})
+		return
+	}
+
+	err = database.EndRestaurantCampaign(email, data.ID)
+	if err != nil {
+		c.JSON(403, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(200, gin.H{})
+}
@@ -0,0 +1,328 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rishabh-bector/BenevolentBitesBack/auth"
+	"github.com/rishabh-bector/BenevolentBitesBack/database"
+	"github.com/rishabh-bector/BenevolentBitesBack/email"
+
+	log "github.com/sirupsen/logrus"
+)
+
+type ClosureData struct {
+	RestID string `json:"restId"`
+	Reason string `json:"reason"`
+	Force  bool   `json:"force"`
+}
+
+// CloseRestaurant allows an admin to begin shutting down a restaurant.
+// Sales are stopped, and every cardholder is emailed their balance.
+func CloseRestaurant(c *gin.Context) {
+	// Obtain and validate google token
+	token, err := c.Cookie("bb-access")
+	if err != nil {
+		log.Error(err)
+		c.JSON(403, gin.H{"error": "sorry bro, unable to find cookie token"})
+		return
+	}
+
+	verify, err := auth.ValidateToken(token)
+	if err != nil {
+		log.Error(err)
+		c.JSON(403, gin.H{"error": err.Error()})
+		return
+	}
+	admin := verify["email"].(string)
+
+	if !auth.IsAdmin(admin) {
+		c.JSON(403, gin.H{"error": "sorry bro, only admins can do that"})
+		return
+	}
+
+	var data ClosureData
+	if err := c.ShouldBindJSON(&data); err != nil {
+		c.JSON(403, gin.H{"error": "sorry bro, invalid json"})
+		return
+	}
+
+	r := database.DoesRestaurantExistUUID(data.RestID)
+	if r.Owner == "nil" {
+		c.JSON(403, gin.H{"error": "sorry bro, that restaurant doesn't exist"})
+		return
+	}
+
+	closure, err := database.StartClosure(r, admin, data.Reason)
+	if err != nil {
+		log.Error(err)
+		c.JSON(403, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Nothing to settle, so the restaurant can be archived straight away
+	if len(closure.Cards) == 0 {
+		err = database.ArchiveRestaurant(r.UUID, admin)
+		if err != nil {
+			log.Error(err)
+			c.JSON(403, gin.H{"error": err.Error()})
+			return
+		}
+	} else {
+		go notifyCardholders(closure)
+	}
+
+	c.JSON(200, closure.Progress())
+}
+
+// notifyCardholders emails everyone holding credit at a closing restaurant
+func notifyCardholders(closure database.Closure) {
+	link := fmt.Sprintf("%s/users/settle?restId=%s", os.Getenv("S_FRONT"), closure.RestUUID)
+
+	for _, card := range closure.Cards {
+		err := email.SendEmail(
+			[]string{card.User},
+			fmt.Sprintf("%s is closing: your Benevolent Bites credit", closure.RestName),
+			fmt.Sprintf(email.ClosureFormat, closure.RestName, float32(card.Balance)/100, link),
+		)
+		if err != nil {
+			log.Error(err)
+			continue
+		}
+
+		err = database.MarkClosureCardNotified(closure.RestUUID, card.Card)
+		if err != nil {
+			log.Error(err)
+		}
+	}
+}
+
+// GetClosures shows admins the progress of every restaurant closure
+func GetClosures(c *gin.Context) {
+	// Obtain and validate google token
+	token, err := c.Cookie("bb-access")
+	if err != nil {
+		log.Error(err)
+		c.JSON(403, gin.H{"error": "sorry bro, unable to find cookie token"})
+		return
+	}
+
+	verify, err := auth.ValidateToken(token)
+	if err != nil {
+		log.Error(err)
+		c.JSON(403, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !auth.IsAdmin(verify["email"].(string)) {
+		c.JSON(403, gin.H{"error": "sorry bro, only admins can do that"})
+		return
+	}
+
+	closures, err := database.GetAllClosures()
+	if err != nil {
+		log.Error(err)
+		c.JSON(403, gin.H{"error": "sorry bro, could not find closures"})
+		return
+	}
+
+	resp := []gin.H{}
+	for i := range closures {
+		resp = append(resp, gin.H{
+			"closure":  closures[i],
+			"progress": closures[i].Progress(),
+		})
+	}
+
+	c.JSON(200, resp)
+}
+
+// ArchiveClosedRestaurant allows an admin to finish a closure. Unless forced,
+// every card has to be settled first.
+func ArchiveClosedRestaurant(c *gin.Context) {
+	// Obtain and validate google token
+	token, err := c.Cookie("bb-access")
+	if err != nil {
+		log.Error(err)
+		c.JSON(403, gin.H{"error": "sorry bro, unable to find cookie token"})
+		return
+	}
+
+	verify, err := auth.ValidateToken(token)
+	if err != nil {
+		log.Error(err)
+		c.JSON(403, gin.H{"error": err.Error()})
+		return
+	}
+
+	admin := verify["email"].(string)
+
+	if !auth.IsAdmin(admin) {
+		c.JSON(403, gin.H{"error": "sorry bro, only admins can do that"})
+		return
+	}
+
+	var data ClosureData
+	if err := c.ShouldBindJSON(&data); err != nil {
+		c.JSON(403, gin.H{"error": "sorry bro, invalid json"})
+		return
+	}
+
+	closure, err := database.GetClosure(data.RestID)
+	if err != nil {
+		c.JSON(403, gin.H{"error": err.Error()})
+		return
+	}
+
+	progress := closure.Progress()
+	if progress.Pending > 0 && !data.Force {
+		c.JSON(403, gin.H{"error": fmt.Sprintf("sorry bro, %d cards still need to be settled", progress.Pending)})
+		return
+	}
+
+	err = database.ArchiveRestaurant(closure.RestUUID, admin)
+	if err != nil {
+		log.Error(err)
+		c.JSON(403, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(200, progress)
+}
+
+type SettleData struct {
+	RestID string `json:"restId"`
+	Choice string `json:"choice"` // "refund" or "donation"
+}
+
+// SettleCards allows a user to choose what happens to their credit at a closing restaurant
+func SettleCards(c *gin.Context) {
+	// Obtain and validate google token
+	token, err := c.Cookie("bb-access")
+	if err != nil {
+		log.Error(err)
+		c.JSON(403, gin.H{"error": "sorry bro, unable to find cookie token"})
+		return
+	}
+
+	verify, err := auth.ValidateToken(token)
+	if err != nil {
+		log.Error(err)
+		c.JSON(403, gin.H{"error": err.Error()})
+		return
+	}
+	user := verify["email"].(string)
+
+	var data SettleData
+	if err := c.ShouldBindJSON(&data); err != nil {
+		c.JSON(403, gin.H{"error": "sorry bro, invalid json"})
+		return
+	}
+
+	if data.Choice != database.TransactionRefund && data.Choice != database.TransactionDonation {
+		c.JSON(403, gin.H{"error": "sorry bro, choose either a refund or a donation"})
+		return
+	}
+
+	closure, err := database.GetClosure(data.RestID)
+	if err != nil || closure.Status != database.ClosureSettling {
+		c.JSON(403, gin.H{"error": "sorry bro, that restaurant isn't closing"})
+		return
+	}
+
+	r := database.DoesRestaurantExistUUID(closure.RestUUID)
+	if r.Owner == "nil" {
+		c.JSON(403, gin.H{"error": "sorry bro, unable to find that restaurant"})
+		return
+	}
+
+	settled, resolved := 0, 0
+	for _, cc := range closure.Cards {
+		if cc.User != user || cc.Resolution != "" {
+			continue
+		}
+
+		// Claim the card first, so two requests can't both refund it
+		claimed, err := database.ClaimClosureCard(closure.RestUUID, cc.Card, data.Choice)
+		if err != nil {
+			log.Error(err)
+			c.JSON(403, gin.H{"error": "sorry bro, could not settle your card"})
+			return
+		}
+		if !claimed {
+			continue
+		}
+
+		resolution, amount, err := settleCard(&r, cc, data.Choice)
+		database.ResolveClosureCard(closure.RestUUID, cc.Card, resolution, err)
+		if err != nil {
+			log.Error(err)
+			c.JSON(403, gin.H{"error": fmt.Sprintf("sorry bro, could not settle your card: %s", err.Error())})
+			return
+		}
+		settled += amount
+		resolved++
+	}
+
+	if resolved == 0 {
+		c.JSON(403, gin.H{"error": "sorry bro, you have no credit left to settle there"})
+		return
+	}
+
+	// Archive the restaurant once the last card is settled
+	closure, err = database.GetClosure(closure.RestUUID)
+	if err == nil && closure.Progress().Pending == 0 {
+		err = database.ArchiveRestaurant(closure.RestUUID, user)
+		if err != nil {
+			log.Error(err)
+		}
+	}
+
+	c.JSON(200, gin.H{"settled": settled, "choice": data.Choice})
+}
+
+// settleCard refunds a card through Square, or converts it to a donation. It returns how
+// the card was resolved, and how much credit was settled.
+func settleCard(r *database.Restaurant, cc database.ClosureCard, choice string) (string, int, error) {
+	card := database.DoesCardExist(cc.Card)
+	if card.UUID == "nil" {
+		return "", 0, fmt.Errorf("card %s no longer exists", cc.Card)
+	}
+
+	// The credit was used up after the closure started, so there's nothing to refund
+	if card.Balance <= 0 {
+		return database.ClosureCardSpent, 0, nil
+	}
+
+	trans := database.Transaction{
+		Amount: -1 * card.Balance,
+		Type:   choice,
+	}
+
+	if choice == database.TransactionRefund {
+		orderID := ""
+		if len(card.Transactions) > 0 {
+			orderID = card.Transactions[0].OrderID
+		}
+
+		if orderID == "" {
+			// Cards from before orders were recorded can't be refunded through Square
+			log.Warn("BB: card ", card.UUID, " of ", card.User, " has no Square order, refund ", card.Balance, " cents by hand")
+			trans.Type = database.TransactionManualRefund
+		} else {
+			// The card's UUID as idempotency key means a retried settlement can't refund twice
+			refundID, err := auth.RefundOrder(&r.Square, orderID, card.Balance, fmt.Sprintf("%s closed", r.Name), card.UUID)
+			if err != nil {
+				return "", 0, err
+			}
+			trans.ID = refundID
+		}
+	}
+
+	if err := database.SettleCard(card.UUID, trans); err != nil {
+		return "", 0, err
+	}
+
+	return trans.Type, card.Balance, nil
+}
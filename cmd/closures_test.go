@@ -0,0 +1,67 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/rishabh-bector/BenevolentBitesBack/database"
+)
+
+// TestSettleLegacyAndSpentCards settles a card bought before Square orders were recorded, and one
+// spent after the closure started. Neither can be refunded through Square, and neither may
+// keep the restaurant from being archived.
+func TestSettleLegacyAndSpentCards(t *testing.T) {
+	router, cleanup := withTestServer(t)
+	defer cleanup()
+	router.POST("/user/settle", SettleCards)
+
+	if err := database.UpdateRestaurant("rest@example.com", database.Restaurant{Name: "Taqueria El Sol"}); err != nil {
+		t.Fatal(err)
+	}
+	r := database.DoesRestaurantExist("rest@example.com")
+
+	legacy, err := database.CreateCard(testOwner, r.UUID, database.Transaction{Amount: 2500, ID: "legacy-payment"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	spent, err := database.CreateCard(testOwner, r.UUID, database.Transaction{Amount: 1000, OrderID: "order-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := database.StartClosure(r, "admin@example.com", "closing for good"); err != nil {
+		t.Fatal(err)
+	}
+	if err := database.SubtractCredit(spent.UUID, 1000, "last visit"); err != nil {
+		t.Fatal(err)
+	}
+
+	w := serve(router, "POST", "/user/settle", `{"restId": "`+r.UUID+`", "choice": "refund"}`)
+	if w.Code != 200 {
+		t.Fatalf("settling: %d %s", w.Code, w.Body.String())
+	}
+
+	var res struct {
+		Settled int `json:"settled"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &res)
+	if res.Settled != 2500 {
+		t.Errorf("expected 2500 cents settled, got %d", res.Settled)
+	}
+
+	if c := database.DoesCardExist(legacy.UUID); c.Balance != 0 {
+		t.Errorf("expected the legacy card to be emptied, it holds %d", c.Balance)
+	}
+
+	closure, err := database.GetClosure(r.UUID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := closure.Progress()
+	if p.Manual != 1 || p.Spent != 1 || p.Pending != 0 {
+		t.Errorf("expected a manual refund and a spent card, got %+v", p)
+	}
+	if closure.Status != database.ClosureArchived {
+		t.Errorf("expected the restaurant to be archived, closure is %s", closure.Status)
+	}
+}
@@ -0,0 +1,278 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rishabh-bector/BenevolentBitesBack/auth"
+	"github.com/rishabh-bector/BenevolentBitesBack/database"
+	"github.com/rishabh-bector/BenevolentBitesBack/email"
+	"github.com/rishabh-bector/BenevolentBitesBack/places"
+
+	log "github.com/sirupsen/logrus"
+)
+
+type FavoriteData struct {
+	RestID   string `json:"restId"`
+	Favorite bool   `json:"favorite"`
+	Follow   bool   `json:"follow"`
+}
+
+// SavedRestaurant is what a user's favorites list shows about a restaurant
+type SavedRestaurant struct {
+	Name        string             `json:"name"`
+	Slug        string             `json:"slug"`
+	RestID      string             `json:"restId"`
+	Description string             `json:"description"`
+	Photos      []string           `json:"photos"`
+	Paused      bool               `json:"paused"`
+	Campaign    *database.Campaign `json:"campaign,omitempty"`
+}
+
+type UpdateData struct {
+	Title string `json:"title"`
+	Body  string `json:"body"`
+}
+
+// SetFavorite adds a restaurant to the user's favorites, or removes it
+func SetFavorite(c *gin.Context) {
+	// Obtain and validate google token
+	token, err := c.Cookie("bb-access")
+	if err != nil {
+		log.Error(err)
+		c.JSON(403, gin.H{"error": "sorry bro, unable to find cookie token"})
+		return
+	}
+
+	verify, err := auth.ValidateToken(token)
+	if err != nil {
+		log.Error(err)
+		c.JSON(403, gin.H{"error": err.Error()})
+		return
+	}
+	email := verify["email"].(string)
+
+	var data FavoriteData
+	if err := c.ShouldBindJSON(&data); err != nil || data.RestID == "" {
+		c.JSON(403, gin.H{"error": "sorry bro, invalid json"})
+		return
+	}
+
+	err = database.SetFavorite(email, data.RestID, data.Favorite)
+	if err != nil {
+		c.JSON(403, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(200, gin.H{})
+}
+
+// SetFollowing makes the user follow a restaurant's campaigns and updates, or stop following them
+func SetFollowing(c *gin.Context) {
+	// Obtain and validate google token
+	token, err := c.Cookie("bb-access")
+	if err != nil {
+		log.Error(err)
+		c.JSON(403, gin.H{"error": "sorry bro, unable to find cookie token"})
+		return
+	}
+
+	verify, err := auth.ValidateToken(token)
+	if err != nil {
+		log.Error(err)
+		c.JSON(403, gin.H{"error": err.Error()})
+		return
+	}
+	email := verify["email"].(string)
+
+	var data FavoriteData
+	if err := c.ShouldBindJSON(&data); err != nil || data.RestID == "" {
+		c.JSON(403, gin.H{"error": "sorry bro, invalid json"})
+		return
+	}
+
+	err = database.SetFollowing(email, data.RestID, data.Follow)
+	if err != nil {
+		c.JSON(403, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(200, gin.H{})
+}
+
+// GetFavorites returns the user's favorite and followed restaurants
+func GetFavorites(c *gin.Context) {
+	// Obtain and validate google token
+	token, err := c.Cookie("bb-access")
+	if err != nil {
+		log.Error(err)
+		c.JSON(403, gin.H{"error": "sorry bro, unable to find cookie token"})
+		return
+	}
+
+	verify, err := auth.ValidateToken(token)
+	if err != nil {
+		log.Error(err)
+		c.JSON(403, gin.H{"error": err.Error()})
+		return
+	}
+	email := verify["email"].(string)
+
+	user, err := database.GetUser(email)
+	if err != nil {
+		log.Error(err)
+		c.JSON(403, gin.H{"error": err.Error()})
+		return
+	}
+
+	favorites, err := savedRestaurants(user.Favorites)
+	if err != nil {
+		c.JSON(403, gin.H{"error": err.Error()})
+		return
+	}
+
+	following, err := savedRestaurants(user.Following)
+	if err != nil {
+		c.JSON(403, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"favorites": favorites,
+		"following": following,
+	})
+}
+
+// PostRestaurantUpdate lets a restaurant owner share news with everyone following them
+func PostRestaurantUpdate(c *gin.Context) {
+	// Obtain and validate google token
+	token, err := c.Cookie("bb-access")
+	if err != nil {
+		log.Error(err)
+		c.JSON(403, gin.H{"error": "sorry bro, unable to find cookie token"})
+		return
+	}
+
+	verify, err := auth.ValidateToken(token)
+	if err != nil {
+		log.Error(err)
+		c.JSON(403, gin.H{"error": err.Error()})
+		return
+	}
+	email := verify["email"].(string)
+
+	var data UpdateData
+	if err := c.ShouldBindJSON(&data); err != nil {
+		c.JSON(403, gin.H{"error": "sorry bro, invalid json"})
+		return
+	}
+
+	update, err := database.PostRestaurantUpdate(email, database.RestaurantUpdate{
+		Title: data.Title,
+		Body:  data.Body,
+	})
+	if err != nil {
+		c.JSON(403, gin.H{"error": err.Error()})
+		return
+	}
+
+	go notifyFollowers(email, updateMessage(update))
+
+	c.JSON(200, update)
+}
+
+// savedRestaurants looks up the published restaurants among the given UUIDs
+func savedRestaurants(uuids []string) ([]SavedRestaurant, error) {
+	rests, err := database.GetRestaurantsByUUIDs(uuids)
+	if err != nil {
+		log.Error(err)
+		return nil, err
+	}
+
+	now := time.Now()
+	saved := []SavedRestaurant{}
+	for i := range rests {
+		r := &rests[i]
+		if !r.Published || r.Archived {
+			continue
+		}
+
+		saved = append(saved, SavedRestaurant{
+			Name:        r.Name,
+			Slug:        r.Slug,
+			RestID:      r.UUID,
+			Description: r.Description,
+			Photos:      r.PhotoURLs(),
+			Paused:      r.Paused,
+			Campaign:    r.ActiveCampaign(now),
+		})
+	}
+
+	return saved, nil
+}
+
+// campaignMessage tells followers about a new campaign
+func campaignMessage(campaign database.Campaign) func(r database.Restaurant, link string) (string, string) {
+	return func(r database.Restaurant, link string) (string, string) {
+		return fmt.Sprintf("%s has started a campaign", r.Name),
+			fmt.Sprintf(email.CampaignFormat, r.Name, campaign.Title, campaign.Description, link)
+	}
+}
+
+// updateMessage passes a restaurant's update on to its followers
+func updateMessage(update database.RestaurantUpdate) func(r database.Restaurant, link string) (string, string) {
+	return func(r database.Restaurant, link string) (string, string) {
+		return fmt.Sprintf("News from %s", r.Name),
+			fmt.Sprintf(email.UpdateFormat, r.Name, update.Title, update.Body, link)
+	}
+}
+
+// notifyFollowers emails everyone following a restaurant. message returns the subject and body of the email.
+func notifyFollowers(owner string, message func(r database.Restaurant, link string) (string, string)) {
+	r := database.DoesRestaurantExist(owner)
+	if r.Owner == "nil" || !r.Published || r.Archived {
+		return
+	}
+
+	followers, err := database.GetFollowers(r.UUID)
+	if err != nil {
+		log.Error(err)
+		return
+	}
+
+	subject, body := message(r, fmt.Sprintf("%s/r/%s", os.Getenv("S_FRONT"), r.Slug))
+	for _, follower := range followers {
+		if err := email.SendEmail([]string{follower}, subject, body); err != nil {
+			log.Error(err)
+		}
+	}
+}
+
+// markFavorites flags the signed in user's favorites among search results.
+// Searching doesn't need an account, so nothing happens without a valid cookie.
+func markFavorites(c *gin.Context, results []places.APIDetails) {
+	token, err := c.Cookie("bb-access")
+	if err != nil {
+		return
+	}
+
+	// Google answers expired tokens without an email, rather than with an error
+	verify, err := auth.ValidateToken(token)
+	if err != nil || verify == nil {
+		return
+	}
+	email, ok := verify["email"].(string)
+	if !ok || email == "" {
+		return
+	}
+
+	user, err := database.GetUser(email)
+	if err != nil {
+		log.Error(err)
+		return
+	}
+
+	places.MarkFavorites(results, user.Favorites)
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/rishabh-bector/BenevolentBitesBack/auth"
+	"github.com/rishabh-bector/BenevolentBitesBack/database"
+	"github.com/rishabh-bector/BenevolentBitesBack/places"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// findEditableRestaurant returns the restaurant a user may look after: their own,
+// or for admins, the one given by restId
+func findEditableRestaurant(email, restID string) database.Restaurant {
+	if restID != "" {
+		r := database.DoesRestaurantExistUUID(restID)
+		if r.Owner == email || auth.IsAdmin(email) {
+			return r
+		}
+		return database.NilRestaurant
+	}
+	return database.DoesRestaurantExist(email)
+}
+
+// GetRestaurantHistory lists every version of a restaurant's profile, with who changed what and when
+func GetRestaurantHistory(c *gin.Context) {
+	// Obtain and validate google token
+	token, err := c.Cookie("bb-access")
+	if err != nil {
+		log.Error(err)
+		c.JSON(403, gin.H{"error": "sorry bro, unable to find cookie token"})
+		return
+	}
+
+	verify, err := auth.ValidateToken(token)
+	if err != nil {
+		log.Error(err)
+		c.JSON(403, gin.H{"error": err.Error()})
+		return
+	}
+	email := verify["email"].(string)
+
+	r := findEditableRestaurant(email, c.Query("restId"))
+	if r.Owner == "nil" {
+		c.JSON(403, gin.H{"error": "sorry bro, unable to find that restaurant"})
+		return
+	}
+
+	versions, err := database.GetRestaurantVersions(r.UUID)
+	if err != nil {
+		log.Error(err)
+		c.JSON(403, gin.H{"error": "sorry bro, unable to find that restaurant's history"})
+		return
+	}
+
+	if !auth.IsAdmin(email) {
+		c.JSON(200, database.OwnerHistory(versions))
+		return
+	}
+
+	c.JSON(200, versions)
+}
+
+type RestoreData struct {
+	RestID  string `json:"restId"`
+	Version int    `json:"version"`
+}
+
+// RestoreRestaurant puts an earlier version of a restaurant's profile back in place
+func RestoreRestaurant(c *gin.Context) {
+	// Obtain and validate google token
+	token, err := c.Cookie("bb-access")
+	if err != nil {
+		log.Error(err)
+		c.JSON(403, gin.H{"error": "sorry bro, unable to find cookie token"})
+		return
+	}
+
+	verify, err := auth.ValidateToken(token)
+	if err != nil {
+		log.Error(err)
+		c.JSON(403, gin.H{"error": err.Error()})
+		return
+	}
+	email := verify["email"].(string)
+
+	var data RestoreData
+	if err := c.ShouldBindJSON(&data); err != nil {
+		c.JSON(403, gin.H{"error": "sorry bro, invalid json"})
+		return
+	}
+
+	r := findEditableRestaurant(email, data.RestID)
+	if r.Owner == "nil" {
+		c.JSON(403, gin.H{"error": "sorry bro, unable to find that restaurant"})
+		return
+	}
+
+	err = database.RestoreRestaurantVersion(r.UUID, data.Version, email)
+	if err != nil {
+		log.Error(err)
+		c.JSON(403, gin.H{"error": err.Error()})
+		return
+	}
+
+	// As in SetRestaurantInfo, an older address makes the cached place stale, and the
+	// restaurant has to be located again for partner search
+	restored := database.DoesRestaurantExistUUID(r.UUID)
+	moved := r.Name != restored.Name || r.Address != restored.Address || r.City != restored.City ||
+		r.State != restored.State || r.Zip != restored.Zip || r.PlaceID != restored.PlaceID
+	if moved {
+		places.InvalidatePlace(r.PlaceID, restored.PlaceID)
+	}
+	if moved || restored.Location == nil {
+		places.LocateRestaurant(restored.Owner, restored.PlaceID)
+	}
+
+	c.JSON(200, gin.H{})
+}
@@ -1,15 +1,18 @@
 package main
 
 import (
-	//"encoding/json"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/rishabh-bector/BenevolentBitesBack/auth"
 	"github.com/rishabh-bector/BenevolentBitesBack/crypto"
 	"github.com/rishabh-bector/BenevolentBitesBack/database"
+	"github.com/rishabh-bector/BenevolentBitesBack/photos"
 	"github.com/rishabh-bector/BenevolentBitesBack/places"
 	"github.com/rishabh-bector/BenevolentBitesBack/twilio"
 
@@ -28,10 +31,21 @@ import (
 // / - returns PROD or DEV environment
 // /oauth - redirected to by Google, exchanges auth code
 // /verify - allows frontend to validate user
+// /r/:slug - returns the public page of a restaurant, old slugs redirect to the current one
 //
 // Search:
 //
-// /search/coords - allows frontend to search for restaurants around coords, given a query string
+// Partners among search results are marked as favorites when the user is signed in.
+//
+// /search/coords - allows frontend to search for restaurants around coords, given a query string,
+//                  optionally filtered by cuisine, dietary, price, minRating, openNow, onPlatformOnly and hasCampaign,
+//                  and sorted by distance, rating or partner. Pass the returned cursor for the next page
+// /search/address - geocodes a zip code, city or street address, then searches around it like /search/coords,
+//                   also returning the resolved center and viewport
+// /search/autocomplete - suggests restaurants for partly typed text, partners first
+// /search/partners - returns partner restaurants nearest to coords, a page at a time, with the same filters
+//                    and sorts as /search/coords. Google is only asked when filtering by price, minRating or openNow,
+//                    or sorting by rating
 //
 // Restaurants:
 //
@@ -40,20 +54,34 @@ import (
 // /rest/getinfo - returns all info
 // /rest/getdetails - returns detailed info about a restaurant using Google's API
 // /rest/setinfo - sets all info
+// /rest/info - PATCH applies a JSON merge patch to the owner editable info
+// /rest/history - lists every version of the restaurant info, with who changed it and when
+// /rest/restore - restores the restaurant info from an earlier version
+// /rest/setslug - changes the slug of the restaurant's public page
 //
 // /rest/getlocations - gets all associated locations from square API
 // /rest/setlocation - sets location for a restaurant
 //
-// /rest/verifycall - calls the restaurants number from Google to verify them
+// /rest/verifycall - calls or texts the restaurants number from Google to verify them, in one of a few languages
+// /rest/verifystatus - returns whether the code was delivered, or the call went unanswered or was busy
 // /rest/verifycode - verifies the call code to that which the user entered
 //
 // /rest/redeemcard - allows restaurant to subtract credit from their issued cards
 // /rest/setpassword - allows restaurant owner to set a password for staff to redeem customer cards
-// /rest/getphoto - returns photo of restaurant from Google Places API
+// /rest/getphoto - returns photo of restaurant from Google Places API, in one of a few widths, cached in the photo store
 // /rest/publish - makes sure that the new restaurant has: information, square, employees, and phone verification
 // /rest/contract - indicates that the restaurant has agreed to the terms of service, and signed the contract
+// /rest/pause - stops or resumes credit sales, without unpublishing the restaurant
+//
+// /rest/addphotos - uploads restaurant photos to the photo store (GCP storage, or local disk), and deletes photos
+// /rest/photos/order - reorders the restaurant's photos
+// /rest/photos/cover - chooses the restaurant's cover photo
+// /rest/photos/caption - sets the caption and alt text of a photo
+//
+// /rest/campaign - starts a fundraising campaign, optionally with a goal and an end date
+// /rest/campaign/end - ends a campaign early
+// /rest/postupdate - posts news to the restaurant's page, and emails it to followers
 //
-// /rest/addphotos - uploads restaurant photos to GCP storage
 // /rest/report - returns all transaction info for a restaurant, given a certain time period
 //
 // Square:
@@ -62,6 +90,11 @@ import (
 // /square/oauth - redirected to by Square, exchanges auth code
 // /square/processcheckout - creates card after a square checkout callback
 //
+// Twilio:
+//
+// /twilio/status - called by Twilio as a verification call or text progresses
+// /twilio/voice - called by Twilio when a language is picked at the end of a verification call
+//
 // Users:
 //
 // /user/signup - creates a new user
@@ -72,6 +105,25 @@ import (
 // /user/getavatar - gets user's google avatar
 // /user/buy - allows user to purchase credit, see BeginPaymentFlow()
 // /user/getcards - returns all of a user's cards and their balances
+// /user/settle - refunds or donates a user's credit at a closing restaurant
+// /user/nominate - asks for a restaurant which isn't on Benevolent Bites yet, by place ID
+// /user/favorite - adds a restaurant to the user's favorites, or removes it
+// /user/follow - follows a restaurant, to be emailed about its campaigns and updates
+// /user/favorites - returns the user's favorite and followed restaurants
+//
+// Admin:
+//
+// /admin/setsplit - sets a new revenue split policy for a restaurant, from a given effective date
+// /admin/getsplits - returns all revenue split policies for a restaurant
+// /admin/setcaps - limits the outstanding credit of a restaurant, and of each of its customers
+//
+// /admin/closerestaurant - stops sales at a restaurant and emails its cardholders to settle their credit
+// /admin/closures - returns the progress of every restaurant closure
+// /admin/archiverestaurant - archives a closing restaurant once its cards are settled
+//
+// /admin/placescache - returns hit and miss counts of the Google Places cache
+// /admin/duplicateplaces - returns places claimed by more than one restaurant
+// /admin/nominations - returns the restaurants users have nominated most
 //
 
 var Router *gin.Engine
@@ -88,6 +140,7 @@ func main() {
 	places.Initialize()
 	twilio.Initialize()
 	crypto.Initialize()
+	photos.Initialize()
 
 	Router = gin.Default()
 
@@ -95,18 +148,25 @@ func main() {
 	log.Info("ORIGINS ALLOWED: ", os.Getenv("S_CORS"), os.Getenv("S_CORS_COMPAT"))
 	config.AllowOrigins = []string{os.Getenv("S_CORS"), os.Getenv("S_CORS_COMPAT")} // For backwards compatibility between .tech and .org domains
 	config.AllowCredentials = true
-	config.AllowMethods = []string{"POST", "GET", "OPTIONS"}
+	config.AllowMethods = []string{"POST", "GET", "PATCH", "OPTIONS"}
 	config.AllowHeaders = []string{"Origin", "Content-Type"}
 
 	Router.Use(cors.New(config))
 	Router.LoadHTMLGlob("../templates/*")
 	Router.Static("/assets", "../assets")
+	if _, ok := photos.Store.(*photos.LocalStore); ok {
+		Router.Static(photos.LocalURL, photos.LocalDir)
+	}
 
 	Router.GET("/", Healthcheck)
 	Router.GET("/oauth", HandleOAuthCode)
 	Router.GET("/verify", VerifyToken)
+	Router.GET("/r/:slug", GetPublicRestaurant)
 
 	Router.GET("/search/coords", SearchCoords)
+	Router.GET("/search/address", SearchAddress)
+	Router.GET("/search/autocomplete", AutocompleteSearch)
+	Router.GET("/search/partners", SearchPartners)
 
 	Router.GET("/rest/signup", StartRESTOAuth2Flow)
 	Router.GET("/rest/getinfo", GetRestaurantInfo)
@@ -114,7 +174,12 @@ func main() {
 	Router.GET("/rest/getphoto", GetRestaurantPhoto)
 	Router.GET("/rest/verifycall", MakeVerifyCall)
 	Router.POST("/rest/verifycode", VerifyCode)
+	Router.GET("/rest/verifystatus", GetVerifyStatus)
 	Router.POST("/rest/setinfo", SetRestaurantInfo)
+	Router.PATCH("/rest/info", PatchRestaurantInfo)
+	Router.GET("/rest/history", GetRestaurantHistory)
+	Router.POST("/rest/restore", RestoreRestaurant)
+	Router.POST("/rest/setslug", SetRestaurantSlug)
 	Router.POST("/rest/setpassword", SetRestaurantPassword)
 	Router.POST("/rest/redeemcard", RedeemCard)
 	Router.GET("/rest/getlocations", GetLocations)
@@ -122,18 +187,45 @@ func main() {
 	Router.GET("/rest/publish", PublishRestaurant)
 	Router.GET("/rest/report", CreateRestaurantReport)
 	Router.GET("/rest/contract", SignContract)
+	Router.GET("/rest/pause", PauseSales)
 	Router.POST("/rest/addphotos", RestAddPhotos)
+	Router.POST("/rest/photos/order", ReorderPhotos)
+	Router.POST("/rest/photos/cover", SetCoverPhoto)
+	Router.POST("/rest/photos/caption", SetPhotoCaption)
+	Router.POST("/rest/campaign", StartCampaign)
+	Router.POST("/rest/campaign/end", EndCampaign)
+	Router.POST("/rest/postupdate", PostRestaurantUpdate)
 
 	Router.GET("/user/signup", StartUSEROAuth2Flow)
 	Router.GET("/user/getavatar", GetUserAvatar)
 	Router.GET("/user/getcards", GetUserCards)
 	Router.GET("/user/buy", BeginPaymentFlow)
+	Router.POST("/user/settle", SettleCards)
+	Router.POST("/user/nominate", NominateRestaurant)
+	Router.POST("/user/favorite", SetFavorite)
+	Router.POST("/user/follow", SetFollowing)
+	Router.GET("/user/favorites", GetFavorites)
+
+	Router.POST("/twilio/status", TwilioStatusCallback)
+	Router.POST("/twilio/voice", TwilioVoiceMenu)
 
 	Router.GET("/square/signup", StartSquareOAuth2Flow)
 	Router.GET("/square/oauth", HandleSquareOAuthCode)
 	Router.GET("/square/processcheckout", ProcessCheckout)
 
+	Router.POST("/admin/setsplit", SetRestaurantSplit)
+	Router.GET("/admin/getsplits", GetRestaurantSplits)
+	Router.POST("/admin/setcaps", SetRestaurantCaps)
+	Router.POST("/admin/closerestaurant", CloseRestaurant)
+	Router.GET("/admin/closures", GetClosures)
+	Router.POST("/admin/archiverestaurant", ArchiveClosedRestaurant)
+	Router.GET("/admin/placescache", GetPlacesCacheStats)
+	Router.GET("/admin/duplicateplaces", GetDuplicatePlaces)
+	Router.GET("/admin/nominations", GetNominations)
+
 	go StartEmployeeReportLoop()
+	go database.AssignMissingSlugs()
+	go places.BackfillLocations()
 
 	Router.Run(os.Getenv("S_PORT")) // listen and serve on 0.0.0.0:8080 (for windows "localhost:8080")
 }
@@ -230,18 +322,36 @@ func SetRestaurantInfo(c *gin.Context) {
 		return
 	}
 
+	// Only owner editable fields are taken from the frontend, everything else is kept as it is
+	base := database.DoesRestaurantExist(email)
+	if base.Owner == "nil" {
+		base = database.Restaurant{}
+	}
+	r = database.ApplyOwnerFields(base, r)
 	r.Owner = email
 
+	if err := r.NormalizeProfile(); err != nil {
+		c.JSON(403, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Anything cached about the old place is stale once the owner edits their address
+	moved := base.Name != r.Name || base.Address != r.Address || base.City != r.City || base.State != r.State || base.Zip != r.Zip
+	if moved {
+		places.InvalidatePlace(base.PlaceID)
+	}
+
 	// Determine PlaceID just in case address changed or new restaurant
 	placeID, err := places.GetPlaceID(r.Name, fmt.Sprintf("%s %s %s %s", r.Address, r.City, r.State, r.Zip))
 	if err != nil {
 		c.JSON(403, gin.H{"error": err.Error()})
 		return
 	}
+	if moved && placeID != base.PlaceID {
+		places.InvalidatePlace(placeID)
+	}
 	r.PlaceID = placeID
 
-	r.Photos = []string{}
-
 	err = database.UpdateRestaurant(email, r)
 	if err != nil {
 		log.Error(err)
@@ -249,6 +359,97 @@ func SetRestaurantInfo(c *gin.Context) {
 		return
 	}
 
+	// Store where the restaurant is for partner search
+	if moved || base.Location == nil {
+		places.LocateRestaurant(email, placeID)
+	}
+
+	// Let users who nominated this place know it has signed up
+	if placeID != base.PlaceID {
+		go notifyNominators(email)
+	}
+
+	c.JSON(200, gin.H{})
+}
+
+// PatchRestaurantInfo applies a JSON merge patch (RFC 7396) from the frontend to the owner editable restaurant info.
+// Unlike SetRestaurantInfo, fields can be cleared by patching them to null.
+func PatchRestaurantInfo(c *gin.Context) {
+	// Obtain and validate token
+	token, err := c.Cookie("bb-access")
+	if err != nil {
+		log.Error(err)
+		c.JSON(403, gin.H{"error": "sorry bro, unable to find cookie token"})
+		return
+	}
+
+	verify, err := auth.ValidateToken(token)
+	if err != nil {
+		log.Error(err)
+		c.JSON(403, gin.H{"error": err.Error()})
+		return
+	}
+	email := verify["email"].(string)
+
+	r := database.DoesRestaurantExist(email)
+	if r.Owner == "nil" {
+		c.JSON(403, gin.H{"error": "sorry bro, that restaurant doesn't exist"})
+		return
+	}
+
+	// Unmarshal frontend patch, which has to be a JSON object
+	body, err := c.GetRawData()
+	if err != nil {
+		c.JSON(403, gin.H{"error": "sorry bro, unable to read request"})
+		return
+	}
+	var patch map[string]interface{}
+	if err := json.Unmarshal(body, &patch); err != nil {
+		c.JSON(403, gin.H{"error": "sorry bro, a merge patch has to be a json object"})
+		return
+	}
+
+	patched, fields, err := database.MergePatchRestaurant(r, patch)
+	if err != nil {
+		c.JSON(403, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Determine PlaceID again if the restaurant might have moved
+	for _, f := range database.LocationFields {
+		if _, ok := patch[f]; ok {
+			places.InvalidatePlace(r.PlaceID)
+
+			placeID, err := places.GetPlaceID(patched.Name, fmt.Sprintf("%s %s %s %s", patched.Address, patched.City, patched.State, patched.Zip))
+			if err != nil {
+				c.JSON(403, gin.H{"error": err.Error()})
+				return
+			}
+			if placeID != r.PlaceID {
+				places.InvalidatePlace(placeID)
+			}
+			patched.PlaceID = placeID
+			fields = append(fields, "placeId")
+			break
+		}
+	}
+
+	err = database.SaveRestaurantFields(email, email, "patch", patched, fields)
+	if err != nil {
+		log.Error(err)
+		c.JSON(403, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Store where the restaurant is for partner search
+	if patched.PlaceID != r.PlaceID || r.Location == nil {
+		places.LocateRestaurant(email, patched.PlaceID)
+	}
+
+	if patched.PlaceID != r.PlaceID {
+		go notifyNominators(email)
+	}
+
 	c.JSON(200, gin.H{})
 }
 
@@ -288,9 +489,27 @@ func GetRestaurantInfo(c *gin.Context) {
 		"description": r.Description,
 		"employees":   r.Employees,
 		"published":   r.Published,
+		"paused":      r.Paused,
+		"closing":     r.Closing,
+		"archived":    r.Archived,
 		"verified":    r.Verified,
 		"signed":      r.Signed,
-		"photos":      r.Photos,
+		"photos":      r.SortedPhotos(),
+		"hours":       r.Hours,
+		"holidays":    r.Holidays,
+		"timezone":    r.TimeZone,
+		"cuisines":    r.Cuisines,
+		"dietary":     r.Dietary,
+		"instagram":   r.Instagram,
+		"ordering":    r.OrderingLink,
+		"story":       r.Story,
+		"campaigns":   r.Campaigns,
+		"slug":        r.Slug,
+		"split":       r.SplitAt(time.Now()),
+		"caps": map[string]int{
+			"maxOutstanding": r.MaxOutstanding,
+			"maxCardBalance": r.MaxCardBalance,
+		},
 	}
 
 	if r.Square.MerchantID != "" {
@@ -507,12 +726,20 @@ func BeginPaymentFlow(c *gin.Context) {
 	}
 
 	amount, err := strconv.Atoi(c.Query("amount"))
-	if err != nil {
+	if err != nil || amount <= 0 {
 		log.Error(err)
 		c.Redirect(303, fmt.Sprintf("%s?error=%s", os.Getenv("S_FRONT"), "sorry bro, invalid amount"))
 		return
 	}
 
+	// Make sure the restaurant is selling, and that this purchase stays under its caps
+	err = database.CanSell(&r, email, amount)
+	if err != nil {
+		log.Info(err)
+		c.Redirect(303, fmt.Sprintf("%s?error=%s", os.Getenv("S_FRONT"), err.Error()))
+		return
+	}
+
 	checkout, err := auth.CreateCheckout(amount, r.Square.LocationID, r.Name, &r.Square)
 	if err != nil {
 		log.Error(err)
@@ -552,6 +779,7 @@ func ProcessCheckout(c *gin.Context) {
 		Timestamp: checkout.Timestamp,
 		Amount:    checkout.Amount,
 		ID:        checkout.ID,
+		OrderID:   checkout.Order.ID,
 	}
 
 	_, err := database.CreateCard(checkout.UserEmail, checkout.RestID, trans)
@@ -599,29 +827,187 @@ func GetUserCards(c *gin.Context) {
 }
 
 func SearchCoords(c *gin.Context) {
+	// The cursor holds everything needed for the next page
+	if cursor := c.Query("cursor"); cursor != "" {
+		s, err := places.SearchPage(cursor)
+		if err != nil {
+			c.JSON(403, gin.H{"error": err.Error()})
+			return
+		}
+		markFavorites(c, s.On)
+
+		c.JSON(200, s)
+		return
+	}
+
 	i, err := strconv.ParseFloat(c.Query("range"), 32)
 	if err != nil {
 		c.JSON(403, gin.H{"error": err.Error()})
 		return
 	}
 
-	s, err := places.SearchCoords(c.Query("query"), c.Query("lat"), c.Query("lng"), i, c.Query("view"))
+	filters, err := searchFilters(c)
+	if err != nil {
+		c.JSON(403, gin.H{"error": err.Error()})
+		return
+	}
+
+	s, err := places.SearchCoords(c.Query("query"), c.Query("lat"), c.Query("lng"), i, c.Query("view"), filters)
 	if err != nil {
 		c.JSON(403, gin.H{"error": err.Error()})
 		return
 	}
+	markFavorites(c, s.On)
 
 	c.JSON(200, s)
 }
 
+// SearchAddress searches for restaurants around a free text address. The range is optional,
+// by default the whole area of the address is searched.
+func SearchAddress(c *gin.Context) {
+	rng := 0.0
+	if s := c.Query("range"); s != "" {
+		var err error
+		rng, err = strconv.ParseFloat(s, 64)
+		if err != nil {
+			c.JSON(403, gin.H{"error": "sorry bro, invalid range"})
+			return
+		}
+	}
+
+	filters, err := searchFilters(c)
+	if err != nil {
+		c.JSON(403, gin.H{"error": err.Error()})
+		return
+	}
+
+	s, err := places.SearchAddress(c.Query("address"), c.Query("query"), rng, c.Query("view"), filters)
+	if err != nil {
+		c.JSON(403, gin.H{"error": err.Error()})
+		return
+	}
+	markFavorites(c, s.On)
+
+	c.JSON(200, s)
+}
+
+// AutocompleteSearch suggests restaurants as the user types, optionally biased to their lat/lng
+func AutocompleteSearch(c *gin.Context) {
+	s, err := places.Autocomplete(c.Query("input"), c.Query("lat"), c.Query("lng"), c.Query("session"))
+	if err != nil {
+		c.JSON(403, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(200, s)
+}
+
+// SearchPartners returns the partner restaurants nearest to the given coords, a page at a time
+func SearchPartners(c *gin.Context) {
+	lat, err := strconv.ParseFloat(c.Query("lat"), 64)
+	if err != nil {
+		c.JSON(403, gin.H{"error": "sorry bro, invalid latitude"})
+		return
+	}
+	lng, err := strconv.ParseFloat(c.Query("lng"), 64)
+	if err != nil {
+		c.JSON(403, gin.H{"error": "sorry bro, invalid longitude"})
+		return
+	}
+
+	rng, err := strconv.ParseFloat(c.DefaultQuery("range", "25"), 64)
+	if err != nil || rng <= 0 {
+		c.JSON(403, gin.H{"error": "sorry bro, invalid range"})
+		return
+	}
+
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		c.JSON(403, gin.H{"error": "sorry bro, invalid page"})
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil || limit < 1 || limit > 50 {
+		c.JSON(403, gin.H{"error": "sorry bro, limit has to be between 1 and 50"})
+		return
+	}
+
+	filters, err := searchFilters(c)
+	if err != nil {
+		c.JSON(403, gin.H{"error": err.Error()})
+		return
+	}
+
+	res, err := places.SearchPartners(lat, lng, rng, filters, page, limit)
+	if err != nil {
+		c.JSON(403, gin.H{"error": err.Error()})
+		return
+	}
+	markFavorites(c, res.Results)
+
+	c.JSON(200, res)
+}
+
+// searchFilters reads the optional search filters from the query string
+func searchFilters(c *gin.Context) (places.SearchFilters, error) {
+	filters := places.SearchFilters{
+		Cuisines:       splitList(c.Query("cuisine")),
+		Dietary:        splitList(c.Query("dietary")),
+		OpenNow:        c.Query("openNow") == "true",
+		OnPlatformOnly: c.Query("onPlatformOnly") == "true",
+		HasCampaign:    c.Query("hasCampaign") == "true",
+		Sort:           c.Query("sort"),
+	}
+
+	for _, p := range splitList(c.Query("price")) {
+		level, err := strconv.Atoi(p)
+		if err != nil {
+			return filters, errors.New("sorry bro, invalid price level")
+		}
+		filters.Price = append(filters.Price, level)
+	}
+
+	if s := c.Query("minRating"); s != "" {
+		rating, err := strconv.ParseFloat(s, 32)
+		if err != nil {
+			return filters, errors.New("sorry bro, invalid rating")
+		}
+		filters.MinRating = float32(rating)
+	}
+
+	return filters, filters.Validate()
+}
+
+// splitList turns a comma separated query parameter into a list
+func splitList(s string) []string {
+	out := []string{}
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
 type RestDetails struct {
-	Name         string   `json:"name"`
-	Description  string   `json:"description"`
-	Address      string   `json:"address"`
-	Website      string   `json:"website"`
-	Image        string   `json:"image"`
-	Phone        string   `json:"phone"`
-	CustomPhotos []string `json:"customPhotos"`
+	Name         string                    `json:"name"`
+	Description  string                    `json:"description"`
+	Address      string                    `json:"address"`
+	Website      string                    `json:"website"`
+	Image        string                    `json:"image"`
+	Phone        string                    `json:"phone"`
+	CustomPhotos []string                  `json:"customPhotos"`
+	Photos       []database.Photo          `json:"photos"`
+	Paused       bool                      `json:"paused"`
+	Hours        []database.OpeningPeriod  `json:"hours"`
+	Holidays     []database.HoursException `json:"holidays"`
+	TimeZone     string                    `json:"timezone"`
+	Cuisines     []string                  `json:"cuisines"`
+	Dietary      []string                  `json:"dietary"`
+	Instagram    string                    `json:"instagram"`
+	OrderingLink string                    `json:"ordering"`
+	Story        string                    `json:"story"`
 }
 
 func GetRestaurantDetails(c *gin.Context) {
@@ -652,21 +1038,60 @@ func GetRestaurantDetails(c *gin.Context) {
 	if dbRest.Owner != "nil" {
 		rd.Name = dbRest.Name
 		rd.Description = dbRest.Description
-		rd.CustomPhotos = dbRest.Photos
+		rd.CustomPhotos = dbRest.PhotoURLs()
+		rd.Photos = dbRest.SortedPhotos()
+		rd.Paused = dbRest.Paused
+		rd.Hours = dbRest.Hours
+		rd.Holidays = dbRest.Holidays
+		rd.TimeZone = dbRest.TimeZone
+		rd.Cuisines = dbRest.Cuisines
+		rd.Dietary = dbRest.Dietary
+		rd.Instagram = dbRest.Instagram
+		rd.OrderingLink = dbRest.OrderingLink
+		rd.Story = dbRest.Story
 	}
 
 	c.JSON(200, rd)
 }
 
+// GetRestaurantPhoto proxies a Google place photo, caching each width in the photo store
 func GetRestaurantPhoto(c *gin.Context) {
 	photoReference := c.Query("photoreference")
+	if photoReference == "" {
+		c.JSON(403, gin.H{"error": "sorry bro, no photo reference given"})
+		return
+	}
 
-	res, contentLength, err := places.GetPlacePhoto(photoReference)
+	width, err := strconv.Atoi(c.DefaultQuery("width", strconv.Itoa(DefaultPlacePhotoWidth)))
+	if err != nil || !intInSlice(width, PlacePhotoWidths) {
+		c.JSON(403, gin.H{"error": fmt.Sprintf("sorry bro, width has to be one of %v", PlacePhotoWidths)})
+		return
+	}
+
+	// A photo reference always shows the same photo, so its key works as an ETag
+	key := placePhotoKey(photoReference, width)
+	etag := fmt.Sprintf(`"%s"`, key)
+	if c.GetHeader("If-None-Match") == etag {
+		c.Header("ETag", etag)
+		c.Header("Cache-Control", "public, max-age=604800")
+		c.Status(304)
+		return
+	}
+
+	data, contentType, err := placePhoto(key, photoReference, width)
+	if err == places.ErrNoPhotos {
+		c.JSON(404, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
-		c.JSON(403, gin.H{"error": err.Error()})
+		log.Error(err)
+		c.JSON(502, gin.H{"error": "sorry bro, unable to get that photo"})
+		return
 	}
 
-	c.DataFromReader(200, contentLength, res.ContentType, res.Data, map[string]string{})
+	c.Header("ETag", etag)
+	c.Header("Cache-Control", "public, max-age=604800")
+	c.Data(200, contentType, data)
 }
 
 func SetRestaurantPassword(c *gin.Context) {
@@ -811,15 +1236,18 @@ func MakeVerifyCall(c *gin.Context) {
 		return
 	}
 
+	method := c.DefaultQuery("method", twilio.MethodVoice)
+	lang, _ := twilio.GetLanguage(c.Query("lang"))
+
 	recipient = strings.Replace(recipient, " ", "", -1)
 	recipient = strings.Replace(recipient, "-", "", -1)
-	err = twilio.MakeConfirmationCall(recipient, email)
+	err = twilio.SendConfirmationCode(recipient, email, method, lang)
 	if err != nil {
 		c.JSON(403, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(200, gin.H{"phone": recipient})
+	c.JSON(200, gin.H{"phone": recipient, "method": method, "lang": lang})
 }
 
 func VerifyCode(c *gin.Context) {
@@ -847,9 +1275,9 @@ func VerifyCode(c *gin.Context) {
 	}
 
 	// Verify code
-	verified := twilio.VerifyCode(email, data["code"])
-	if !verified {
-		c.JSON(403, gin.H{"error": "sorry bro, wrong code"})
+	err = twilio.VerifyCode(email, data["code"])
+	if err != nil {
+		c.JSON(403, gin.H{"error": err.Error()})
 		return
 	}
 
@@ -927,6 +1355,40 @@ func PublishRestaurant(c *gin.Context) {
 	c.JSON(200, gin.H{})
 }
 
+// PauseSales allows a restaurant owner to stop or resume credit sales, while staying visible in search
+func PauseSales(c *gin.Context) {
+	// Obtain and validate google token
+	token, err := c.Cookie("bb-access")
+	if err != nil {
+		log.Error(err)
+		c.JSON(403, gin.H{"error": "Unable to find cookie token. Please login again."})
+		return
+	}
+
+	verify, err := auth.ValidateToken(token)
+	if err != nil {
+		log.Error(err)
+		c.JSON(403, gin.H{"error": err.Error()})
+		return
+	}
+	owner := verify["email"].(string)
+
+	paused, err := strconv.ParseBool(c.Query("paused"))
+	if err != nil {
+		c.JSON(403, gin.H{"error": "sorry bro, paused must be true or false"})
+		return
+	}
+
+	err = database.SetRestaurantPaused(owner, paused)
+	if err != nil {
+		log.Error(err)
+		c.JSON(403, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(200, gin.H{"paused": paused})
+}
+
 func SignContract(c *gin.Context) {
 	// Obtain and validate google token
 	token, err := c.Cookie("bb-access")
@@ -999,28 +1461,42 @@ func RestAddPhotos(c *gin.Context) {
 		}
 	}
 
-	links, err := UploadPhotos(files)
+	uploads, err := UploadPhotos(files)
 	if err != nil {
-		c.JSON(403, gin.H{"error": "sorry bro, could not upload photo to storage server"})
+		c.JSON(403, gin.H{"error": fmt.Sprintf("sorry bro, could not upload photo: %s", err.Error())})
+		return
+	}
+	restDb.Photos = restDb.SortedPhotos()
+	for _, up := range uploads {
+		restDb.Photos = append(restDb.Photos, database.Photo{
+			ID:       up.ID,
+			URL:      up.DisplayURL,
+			ThumbURL: up.ThumbURL,
+			Order:    len(restDb.Photos),
+		})
 	}
-	restDb.Photos = append(restDb.Photos, links...)
 
+	// Deleted photos are given by ID, or by url from older frontends
+	var removed []database.Photo
 	if dok {
-		for _, url := range deleted {
-			for i, v := range restDb.Photos {
-				if v == url {
-					restDb.Photos[i] = restDb.Photos[len(restDb.Photos)-1]
-					restDb.Photos[len(restDb.Photos)-1] = ""
-					restDb.Photos = restDb.Photos[:len(restDb.Photos)-1]
-				}
+		for _, id := range deleted {
+			if i, ok := restDb.FindPhoto(id); ok {
+				removed = append(removed, restDb.Photos[i])
+				restDb.Photos = append(restDb.Photos[:i], restDb.Photos[i+1:]...)
 			}
 		}
 	}
 
-	err = database.UpdateRestaurant(owner, restDb)
+	err = database.SaveRestaurantPhotos(owner, restDb)
 	if err != nil {
 		c.JSON(403, gin.H{"error": "sorry bro, could not update restaurant photos"})
+		return
 	}
 
-	c.JSON(200, gin.H{})
+	// Only remove stored photos once nothing points at them anymore
+	for _, p := range removed {
+		removeStoredPhoto(p)
+	}
+
+	c.JSON(200, gin.H{"photos": restDb.SortedPhotos()})
 }
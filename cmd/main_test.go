@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rishabh-bector/BenevolentBitesBack/auth"
+	"github.com/rishabh-bector/BenevolentBitesBack/database"
+	"github.com/rishabh-bector/BenevolentBitesBack/places"
+
+	"googlemaps.github.io/maps"
+)
+
+// testOwner is who every token belongs to in these tests
+const testOwner = "owner@example.com"
+
+// testPlaceID is the fixture every address is found at
+const testPlaceID = "ChIJfixtureTaqueriaElSol"
+
+// stubPlaces replays the places fixtures, and finds testPlaceID for any address
+type stubPlaces struct {
+	*places.FixtureProvider
+}
+
+func (stubPlaces) FindPlace(input string, fields string) ([]maps.PlacesSearchResult, error) {
+	return []maps.PlacesSearchResult{{PlaceID: testPlaceID}}, nil
+}
+
+// withTestServer runs handlers against a scratch database on the Mongo at M_URL, the places
+// fixtures, and a token check which says every token is testOwner's. It returns a router
+// without any routes, and a func to put everything back. Without M_URL the test is skipped.
+func withTestServer(t *testing.T) (*gin.Engine, func()) {
+	if os.Getenv("M_URL") == "" {
+		t.Skip("M_URL isn't set")
+	}
+
+	old := os.Getenv("M_DB")
+	os.Setenv("M_DB", fmt.Sprintf("bb_test_%d", time.Now().UnixNano()))
+	database.Initialize()
+	db := database.Client.Database(os.Getenv("M_DB"))
+	os.Setenv("M_DB", old)
+
+	tokens := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"email": "` + testOwner + `"}`))
+	}))
+	oldTokenInfo := auth.TokenInfoURL
+	auth.TokenInfoURL = tokens.URL
+
+	oldPlaces := places.SetProvider(stubPlaces{&places.FixtureProvider{Dir: "../places/fixtures"}})
+
+	gin.SetMode(gin.TestMode)
+
+	return gin.New(), func() {
+		places.SetProvider(oldPlaces)
+		auth.TokenInfoURL = oldTokenInfo
+		tokens.Close()
+		db.Drop(context.Background())
+		database.Client.Disconnect(context.Background())
+	}
+}
+
+// serve sends a request to router as testOwner
+func serve(router *gin.Engine, method, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.AddCookie(&http.Cookie{Name: "bb-access", Value: "test-token"})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
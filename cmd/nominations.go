@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rishabh-bector/BenevolentBitesBack/auth"
+	"github.com/rishabh-bector/BenevolentBitesBack/database"
+	"github.com/rishabh-bector/BenevolentBitesBack/email"
+	"github.com/rishabh-bector/BenevolentBitesBack/places"
+
+	log "github.com/sirupsen/logrus"
+)
+
+type NominationData struct {
+	PlaceID string `json:"placeId"`
+}
+
+// NominateRestaurant lets a user ask for a restaurant which isn't on Benevolent Bites yet
+func NominateRestaurant(c *gin.Context) {
+	// Obtain and validate google token
+	token, err := c.Cookie("bb-access")
+	if err != nil {
+		log.Error(err)
+		c.JSON(403, gin.H{"error": "sorry bro, unable to find cookie token"})
+		return
+	}
+
+	verify, err := auth.ValidateToken(token)
+	if err != nil {
+		log.Error(err)
+		c.JSON(403, gin.H{"error": err.Error()})
+		return
+	}
+	email := verify["email"].(string)
+
+	var data NominationData
+	if err := c.ShouldBindJSON(&data); err != nil || data.PlaceID == "" {
+		c.JSON(403, gin.H{"error": "sorry bro, invalid json"})
+		return
+	}
+
+	if r := database.DoesRestaurantExistPlaceID(data.PlaceID); r.Owner != "nil" {
+		c.JSON(403, gin.H{"error": "sorry bro, that restaurant is already on Benevolent Bites"})
+		return
+	}
+
+	// Makes sure the place exists, and gives admins a name to go with it
+	details, err := places.GetPlaceDetails(data.PlaceID)
+	if err != nil {
+		c.JSON(403, gin.H{"error": "sorry bro, unable to find that restaurant"})
+		return
+	}
+
+	nom, err := database.NominateRestaurant(data.PlaceID, details.Name, details.FormattedAddress, email)
+	if err != nil {
+		c.JSON(403, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(200, gin.H{"votes": nom.Votes})
+}
+
+// GetNominations shows admins which restaurants users ask for most
+func GetNominations(c *gin.Context) {
+	// Obtain and validate google token
+	token, err := c.Cookie("bb-access")
+	if err != nil {
+		log.Error(err)
+		c.JSON(403, gin.H{"error": "sorry bro, unable to find cookie token"})
+		return
+	}
+
+	verify, err := auth.ValidateToken(token)
+	if err != nil {
+		log.Error(err)
+		c.JSON(403, gin.H{"error": err.Error()})
+		return
+	}
+	email := verify["email"].(string)
+
+	if !auth.IsAdmin(email) {
+		c.JSON(403, gin.H{"error": "sorry bro, only admins can do that"})
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit < 1 {
+		c.JSON(403, gin.H{"error": "sorry bro, invalid limit"})
+		return
+	}
+
+	noms, err := database.GetTopNominations(limit)
+	if err != nil {
+		log.Error(err)
+		c.JSON(403, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(200, noms)
+}
+
+// notifyNominators emails everyone who nominated a restaurant's place once it signs up
+func notifyNominators(owner string) {
+	r := database.DoesRestaurantExist(owner)
+	if r.Owner == "nil" || r.PlaceID == "" {
+		return
+	}
+
+	nom, claimed, err := database.ClaimNomination(r.PlaceID, r.UUID)
+	if err != nil {
+		log.Error(err)
+		return
+	}
+	if !claimed {
+		return
+	}
+
+	link := fmt.Sprintf("%s/r/%s", os.Getenv("S_FRONT"), r.Slug)
+	for _, voter := range nom.Voters {
+		err := email.SendEmail(
+			[]string{voter},
+			fmt.Sprintf("%s has joined Benevolent Bites", r.Name),
+			fmt.Sprintf(email.NominationFormat, r.Name, link),
+		)
+		if err != nil {
+			log.Error(err)
+		}
+	}
+}
@@ -1,69 +1,190 @@
 package main
 
 import (
-	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
 	"io"
+	"io/ioutil"
 	"mime/multipart"
-	"time"
+	"net/http"
 
-	"cloud.google.com/go/storage"
+	"github.com/gin-gonic/gin"
 	"github.com/rishabh-bector/BenevolentBitesBack/auth"
+	"github.com/rishabh-bector/BenevolentBitesBack/database"
+	"github.com/rishabh-bector/BenevolentBitesBack/photos"
+	"github.com/rishabh-bector/BenevolentBitesBack/places"
 	log "github.com/sirupsen/logrus"
 )
 
-// Uploads photo from restaurant to Google Cloud Storage DB
-func UploadPhotos(fhs []*multipart.FileHeader) ([]string, error) {
-	ctx := context.Background()
+// Uploads photos from a restaurant to the photo store, with thumbnail and display variants
+func UploadPhotos(fhs []*multipart.FileHeader) ([]photos.Upload, error) {
+	var uploads []photos.Upload
+	for _, fh := range fhs {
+		up, err := photos.SaveUpload(fh)
+		if err != nil {
+			log.Error(err)
 
-	// Creates a client.
-	client, err := storage.NewClient(ctx)
-	if err != nil {
-		log.Error(err)
-		return []string{}, err
+			// Don't leave half of a failed batch behind
+			for _, u := range uploads {
+				photos.DeleteUpload(u.ID)
+			}
+			return []photos.Upload{}, err
+		}
+		uploads = append(uploads, up)
 	}
 
-	// Sets the name for the new bucket.
-	bucketName := "benevolentbites-restaurant-photos"
+	return uploads, nil
+}
 
-	var attrs []string
-	for _, fh := range fhs {
-		f, err := fh.Open()
-		if err != nil {
-			log.Error(err)
-			return []string{}, err
+// removeStoredPhoto deletes every stored variant of a photo
+func removeStoredPhoto(p database.Photo) {
+	var err error
+	if p.IsLegacy() {
+		if key := photos.MediaLinkKey(p.URL); key != "" {
+			err = photos.Store.Delete(key)
 		}
+	} else {
+		err = photos.DeleteUpload(p.ID)
+	}
+	if err != nil {
+		log.Error("BB: unable to delete stored photo: ", err)
+	}
+}
+
+type PhotoData struct {
+	IDs     []string `json:"ids"`
+	ID      string   `json:"id"`
+	Caption string   `json:"caption"`
+	Alt     string   `json:"alt"`
+}
 
-		// Creates new object
-		obj := client.Bucket(bucketName).Object(auth.GenerateUUID())
+// ReorderPhotos allows a restaurant owner to choose the order their photos are shown in
+func ReorderPhotos(c *gin.Context) {
+	editPhotos(c, func(r *database.Restaurant, data PhotoData) error {
+		return r.ReorderPhotos(data.IDs)
+	})
+}
 
-		wc := obj.NewWriter(ctx)
+// SetCoverPhoto allows a restaurant owner to choose the photo shown first
+func SetCoverPhoto(c *gin.Context) {
+	editPhotos(c, func(r *database.Restaurant, data PhotoData) error {
+		return r.SetCoverPhoto(data.ID)
+	})
+}
 
-		// Writes photo to bucket object
-		ctx, cancel := context.WithTimeout(ctx, time.Second*15)
-		defer cancel()
-		if _, err := io.Copy(wc, f); err != nil {
-			log.Error(err)
-			return []string{}, err
+// SetPhotoCaption allows a restaurant owner to describe one of their photos
+func SetPhotoCaption(c *gin.Context) {
+	editPhotos(c, func(r *database.Restaurant, data PhotoData) error {
+		i, ok := r.FindPhoto(data.ID)
+		if !ok {
+			return errors.New("sorry bro, unable to find that photo")
 		}
-		if err := wc.Close(); err != nil {
-			log.Error(err)
-			return []string{}, err
+		if len(data.Caption) > 300 || len(data.Alt) > 300 {
+			return errors.New("sorry bro, please keep captions under 300 characters")
 		}
+		r.Photos[i].Caption = data.Caption
+		r.Photos[i].Alt = data.Alt
+		return nil
+	})
+}
 
-		// Sets object to public reading permissions
-		if err := obj.ACL().Set(ctx, storage.AllUsers, storage.RoleReader); err != nil {
-			log.Error(err)
-			return []string{}, err
-		}
+// editPhotos applies a change to the photos of the caller's restaurant
+func editPhotos(c *gin.Context, edit func(*database.Restaurant, PhotoData) error) {
+	// Obtain and validate google token
+	token, err := c.Cookie("bb-access")
+	if err != nil {
+		log.Error(err)
+		c.JSON(403, gin.H{"error": "Unable to find cookie token. Please login again."})
+		return
+	}
 
-		// Gets object attributes (including public URL)
-		attr, err := obj.Attrs(ctx)
-		if err != nil {
-			log.Error(err.Error())
-			return []string{}, err
-		}
-		attrs = append(attrs, attr.MediaLink)
+	verify, err := auth.ValidateToken(token)
+	if err != nil {
+		log.Error(err)
+		c.JSON(403, gin.H{"error": err.Error()})
+		return
+	}
+	owner := verify["email"].(string)
+
+	restDb := database.DoesRestaurantExist(owner)
+	if restDb.Owner == "nil" {
+		c.JSON(403, gin.H{"error": "sorry bro, could not find that restaurant"})
+		return
 	}
 
-	return attrs, nil
+	var data PhotoData
+	if err := c.ShouldBindJSON(&data); err != nil {
+		c.JSON(403, gin.H{"error": "sorry bro, invalid json"})
+		return
+	}
+
+	if err := edit(&restDb, data); err != nil {
+		c.JSON(403, gin.H{"error": err.Error()})
+		return
+	}
+
+	err = database.SaveRestaurantPhotos(owner, restDb)
+	if err != nil {
+		log.Error(err)
+		c.JSON(403, gin.H{"error": "sorry bro, could not update restaurant photos"})
+		return
+	}
+
+	c.JSON(200, gin.H{"photos": restDb.SortedPhotos()})
+}
+
+// Widths Google place photos can be requested in, so the cache only ever holds a few variants
+var (
+	PlacePhotoWidths       = []int{200, 400, 800, 1600}
+	DefaultPlacePhotoWidth = 400
+)
+
+// placePhotoKey names the cached copy of a place photo in the photo store
+func placePhotoKey(ref string, width int) string {
+	sum := sha1.Sum([]byte(ref))
+	return fmt.Sprintf("place-%s-%d", hex.EncodeToString(sum[:10]), width)
+}
+
+// placePhoto returns a place photo from the photo store, fetching and storing it first if needed
+func placePhoto(key, ref string, width int) ([]byte, string, error) {
+	data, contentType, err := photos.Store.Get(key)
+	if err == nil {
+		return data, contentType, nil
+	}
+	if err != photos.ErrNotFound {
+		log.Error("BB: unable to read cached place photo: ", err)
+	}
+
+	res, _, err := places.GetPlacePhoto(ref, width)
+	if err != nil {
+		return nil, "", err
+	}
+	defer res.Data.Close()
+
+	data, err = ioutil.ReadAll(&io.LimitedReader{R: res.Data, N: photos.MaxUploadSize})
+	if err != nil {
+		return nil, "", err
+	}
+
+	contentType = res.ContentType
+	if contentType == "" {
+		contentType = http.DetectContentType(data)
+	}
+
+	if _, err := photos.Store.Put(key, contentType, data); err != nil {
+		log.Error("BB: unable to cache place photo: ", err)
+	}
+
+	return data, contentType, nil
+}
+
+func intInSlice(a int, list []int) bool {
+	for _, b := range list {
+		if b == a {
+			return true
+		}
+	}
+	return false
 }
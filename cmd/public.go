@@ -0,0 +1,176 @@
+package main
+
+import (
+	"crypto/sha1"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rishabh-bector/BenevolentBitesBack/auth"
+	"github.com/rishabh-bector/BenevolentBitesBack/database"
+	"github.com/rishabh-bector/BenevolentBitesBack/places"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// PublicProfile is everything the public page of a restaurant shows
+type PublicProfile struct {
+	RestDetails
+	Slug         string   `json:"slug"`
+	RestID       string   `json:"restId"`
+	GooglePhotos []string `json:"googlePhotos"`
+	Rating       float32  `json:"rating"`
+	PriceLevel   int      `json:"priceLevel"`
+	Impact       Impact   `json:"impact"`
+	BuyLink      string   `json:"buyLink"`
+
+	Campaign *database.Campaign          `json:"campaign,omitempty"`
+	Updates  []database.RestaurantUpdate `json:"updates"` // Newest first
+}
+
+// Impact sums up what a restaurant's supporters have done for it
+type Impact struct {
+	Sold       int `json:"sold"`       // Credit bought, in cents
+	Redeemed   int `json:"redeemed"`   // Credit spent at the restaurant, in cents
+	ToStaff    int `json:"toStaff"`    // Share of sales owed to employees, in cents
+	Supporters int `json:"supporters"` // Number of people who bought credit
+}
+
+// GetPublicRestaurant returns the public profile of a published restaurant, by its slug
+func GetPublicRestaurant(c *gin.Context) {
+	slug := c.Param("slug")
+
+	r, moved := database.DoesRestaurantExistSlug(slug)
+	if r.Owner == "nil" || !r.Published || r.Archived {
+		c.JSON(404, gin.H{"error": "sorry bro, unable to find that restaurant"})
+		return
+	}
+
+	// Old slugs redirect to the restaurant's current page
+	if moved {
+		c.Redirect(301, fmt.Sprintf("/r/%s", url.PathEscape(r.Slug)))
+		return
+	}
+
+	profile := PublicProfile{
+		Slug:         r.Slug,
+		RestID:       r.UUID,
+		GooglePhotos: []string{},
+		Impact:       calcImpact(&r),
+		BuyLink:      fmt.Sprintf("/user/buy?restId=%s", url.QueryEscape(r.UUID)),
+		Campaign:     r.ActiveCampaign(time.Now()),
+		Updates:      r.LatestUpdates(10),
+	}
+
+	// Google fills in anything the restaurant hasn't told us
+	pd, err := places.GetPlaceDetails(r.PlaceID)
+	if err != nil {
+		log.Error(err)
+	} else {
+		profile.Address = pd.FormattedAddress
+		profile.Phone = pd.InternationalPhoneNumber
+		profile.Website = pd.Website
+		profile.Rating = pd.Rating
+		profile.PriceLevel = pd.PriceLevel
+		for _, p := range pd.Photos {
+			profile.GooglePhotos = append(profile.GooglePhotos, p.PhotoReference)
+		}
+		if len(profile.GooglePhotos) > 0 {
+			profile.Image = profile.GooglePhotos[0]
+		}
+	}
+
+	profile.Name = r.Name
+	profile.Description = r.Description
+	profile.CustomPhotos = r.PhotoURLs()
+	profile.Photos = r.SortedPhotos()
+	profile.Paused = r.Paused
+	profile.Hours = r.Hours
+	profile.Holidays = r.Holidays
+	profile.TimeZone = r.TimeZone
+	profile.Cuisines = r.Cuisines
+	profile.Dietary = r.Dietary
+	profile.Instagram = r.Instagram
+	profile.OrderingLink = r.OrderingLink
+	profile.Story = r.Story
+	if r.Website != "" {
+		profile.Website = r.Website
+	}
+
+	body, err := json.Marshal(profile)
+	if err != nil {
+		log.Error(err)
+		c.JSON(500, gin.H{"error": "sorry bro, unable to build that page"})
+		return
+	}
+
+	etag := fmt.Sprintf("\"%x\"", sha1.Sum(body))
+	c.Header("Cache-Control", "public, max-age=300")
+	c.Header("ETag", etag)
+	if c.GetHeader("If-None-Match") == etag {
+		c.Status(304)
+		return
+	}
+
+	c.Data(200, "application/json; charset=utf-8", body)
+}
+
+// calcImpact totals up every card a restaurant has sold
+func calcImpact(r *database.Restaurant) Impact {
+	cards, err := database.GetRestaurantCards(r.UUID)
+	if err != nil {
+		log.Error(err)
+		return Impact{}
+	}
+
+	var start time.Time
+	stats := CalcStats(start, cards)
+	shares := CalcShares(start, cards, r)
+
+	supporters := map[string]bool{}
+	for _, card := range cards {
+		supporters[card.User] = true
+	}
+
+	return Impact{
+		Sold:       stats.Total,
+		Redeemed:   stats.TotalRedeemed,
+		ToStaff:    shares.Employees,
+		Supporters: len(supporters),
+	}
+}
+
+// SetRestaurantSlug allows a restaurant owner to change the address of their public page
+func SetRestaurantSlug(c *gin.Context) {
+	// Obtain and validate google token
+	token, err := c.Cookie("bb-access")
+	if err != nil {
+		log.Error(err)
+		c.JSON(403, gin.H{"error": "sorry bro, unable to find cookie token"})
+		return
+	}
+
+	verify, err := auth.ValidateToken(token)
+	if err != nil {
+		log.Error(err)
+		c.JSON(403, gin.H{"error": err.Error()})
+		return
+	}
+	email := verify["email"].(string)
+
+	var data map[string]string
+	if err := c.ShouldBindJSON(&data); err != nil {
+		c.JSON(403, gin.H{"error": "sorry bro, invalid json"})
+		return
+	}
+
+	err = database.ChangeRestaurantSlug(email, data["slug"])
+	if err != nil {
+		c.JSON(403, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(200, gin.H{"slug": data["slug"]})
+}
@@ -16,6 +16,7 @@ type RestaurantReport struct {
 	Total      int `json:"total"`
 	Restaurant int `json:"restaurant"`
 	Employees  int `json:"employees"`
+	Platform   int `json:"platform"`
 
 	Transactions []ReportTransaction `json:"transactions"`
 	Sales        []ReportTransaction `json:"sales"`
@@ -68,14 +69,13 @@ func CreateRestaurantReport(c *gin.Context) {
 
 	restStats := CalcStats(startTime, cards)
 	restTrans := CalcTrans(startTime, cards)
-
-	employees := int(float32(restStats.Total) * 0.25)
-	restaurant := int(float32(restStats.Total) * 0.75)
+	restShares := CalcShares(startTime, cards, &restDb)
 
 	c.JSON(200, RestaurantReport{
 		Total:        restStats.Total,
-		Employees:    employees,
-		Restaurant:   restaurant,
+		Employees:    restShares.Employees,
+		Restaurant:   restShares.Restaurant,
+		Platform:     restShares.Platform,
 		Transactions: restTrans.Redeems,
 		Sales:        restTrans.Sales,
 		Outstanding:  restStats.Outstanding,
@@ -135,9 +135,9 @@ func CreateEmployeeReport(rest *database.Restaurant) EmployeeReport {
 	}
 
 	startTime := FindStartOf("week")
-	restStats := CalcStats(startTime, cards)
+	restShares := CalcShares(startTime, cards, rest)
 
-	individualAmnt := int(float32(restStats.Total)*0.25) / int(len(rest.Employees))
+	individualAmnt := restShares.Employees / int(len(rest.Employees))
 
 	return EmployeeReport{
 		Week:   startTime,
@@ -200,6 +200,38 @@ func CalcStats(startTime time.Time, cards []database.Card) RestStats {
 	}
 }
 
+type RestShares struct {
+	Employees  int // Credit owed to employees in the given time range
+	Restaurant int // Credit owed to the restaurant in the given time range
+	Platform   int // Credit kept by the platform in the given time range
+}
+
+// CalcShares splits every sale in the given time range using the
+// policy which was in effect for the restaurant when the sale happened
+func CalcShares(startTime time.Time, cards []database.Card, rest *database.Restaurant) RestShares {
+	var shares RestShares
+
+	for c := range cards {
+		sale := cards[c].Transactions[0]
+		created, err := time.Parse(time.RFC3339, sale.Timestamp)
+		if err != nil {
+			log.Info(err)
+			continue
+		}
+
+		if created.Before(startTime) {
+			continue
+		}
+
+		employees, restaurant, platform := rest.SplitAt(created).Apply(sale.Amount)
+		shares.Employees += employees
+		shares.Restaurant += restaurant
+		shares.Platform += platform
+	}
+
+	return shares
+}
+
 type RestTransactions struct {
 	Redeems []ReportTransaction // All redemptions in the given time range
 	Sales   []ReportTransaction // All credit purchases in the given time range
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/rishabh-bector/BenevolentBitesBack/database"
+)
+
+func TestSetRestaurantInfoProfile(t *testing.T) {
+	router, cleanup := withTestServer(t)
+	defer cleanup()
+	router.POST("/rest/setinfo", SetRestaurantInfo)
+
+	body := `{
+		"name": "Taqueria El Sol",
+		"address": "1100 E 6th St",
+		"city": "Austin",
+		"state": "TX",
+		"zip": "78702",
+		"hours": [{"day": 1, "open": "09:00", "close": "17:00"}],
+		"holidays": [{"date": "2020-12-25", "closed": true}],
+		"cuisines": ["Mexican", "tacos"]
+	}`
+	if w := serve(router, "POST", "/rest/setinfo", body); w.Code != 200 {
+		t.Fatalf("creating restaurant: %d %s", w.Code, w.Body.String())
+	}
+
+	r := database.DoesRestaurantExist(testOwner)
+	if len(r.Hours) != 1 || r.Hours[0] != (database.OpeningPeriod{Day: 1, Open: "09:00", Close: "17:00"}) {
+		t.Errorf("expected the hours to be saved, got %+v", r.Hours)
+	}
+	if len(r.Holidays) != 1 || r.Holidays[0].Date != "2020-12-25" || !r.Holidays[0].Closed {
+		t.Errorf("expected the holidays to be saved, got %+v", r.Holidays)
+	}
+	if len(r.Cuisines) != 2 || r.Cuisines[0] != "mexican" {
+		t.Errorf("expected the cuisines to be saved, got %v", r.Cuisines)
+	}
+
+	// Saving again changes the lists of an existing restaurant, and keeps the ones left out
+	body = `{"hours": [{"day": 2, "open": "10:00", "close": "14:00"}], "cuisines": ["tacos"]}`
+	if w := serve(router, "POST", "/rest/setinfo", body); w.Code != 200 {
+		t.Fatalf("updating restaurant: %d %s", w.Code, w.Body.String())
+	}
+
+	r = database.DoesRestaurantExist(testOwner)
+	if len(r.Hours) != 1 || r.Hours[0].Day != 2 {
+		t.Errorf("expected the new hours, got %+v", r.Hours)
+	}
+	if len(r.Holidays) != 1 {
+		t.Errorf("expected the holidays to be kept, got %+v", r.Holidays)
+	}
+	if len(r.Cuisines) != 1 || r.Cuisines[0] != "tacos" {
+		t.Errorf("expected the new cuisines, got %v", r.Cuisines)
+	}
+}
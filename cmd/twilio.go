@@ -0,0 +1,99 @@
+package main
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/rishabh-bector/BenevolentBitesBack/auth"
+	"github.com/rishabh-bector/BenevolentBitesBack/database"
+	"github.com/rishabh-bector/BenevolentBitesBack/twilio"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// GetVerifyStatus tells the frontend how the last verification call or text went,
+// so it can suggest texting instead when nobody picked up
+func GetVerifyStatus(c *gin.Context) {
+	// Obtain and validate google token
+	token, err := c.Cookie("bb-access")
+	if err != nil {
+		log.Error(err)
+		c.JSON(403, gin.H{"error": "sorry bro, unable to find cookie token"})
+		return
+	}
+
+	verify, err := auth.ValidateToken(token)
+	if err != nil {
+		log.Error(err)
+		c.JSON(403, gin.H{"error": err.Error()})
+		return
+	}
+	email := verify["email"].(string)
+
+	v, err := database.GetVerification(email)
+	if err != nil {
+		c.JSON(403, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"phone":   v.Phone,
+		"method":  v.Method,
+		"status":  v.Status,
+		"failed":  v.Failed(),
+		"expires": v.Expires,
+	})
+}
+
+// TwilioStatusCallback records the progress of a verification call or text, as reported by Twilio
+func TwilioStatusCallback(c *gin.Context) {
+	if !validTwilioRequest(c) {
+		c.JSON(403, gin.H{"error": "sorry bro, invalid signature"})
+		return
+	}
+
+	sid, status := c.PostForm("CallSid"), c.PostForm("CallStatus")
+	if sid == "" {
+		sid, status = c.PostForm("MessageSid"), c.PostForm("MessageStatus")
+	}
+	if sid == "" || status == "" {
+		c.JSON(403, gin.H{"error": "sorry bro, missing sid or status"})
+		return
+	}
+
+	if err := database.SetVerificationStatus(sid, status); err != nil {
+		log.Error(err)
+		c.JSON(500, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Status(204)
+}
+
+// TwilioVoiceMenu reads the code again in the language picked at the end of a verification call
+func TwilioVoiceMenu(c *gin.Context) {
+	if !validTwilioRequest(c) {
+		c.JSON(403, gin.H{"error": "sorry bro, invalid signature"})
+		return
+	}
+
+	twiml, err := twilio.MenuTwiML(c.Query("t"), c.PostForm("Digits"))
+	if err != nil {
+		log.Error(err)
+		c.JSON(403, gin.H{"error": "sorry bro, invalid token"})
+		return
+	}
+
+	c.Data(200, "text/xml", []byte(twiml))
+}
+
+// validTwilioRequest checks the signature Twilio puts on its callbacks
+func validTwilioRequest(c *gin.Context) bool {
+	if err := c.Request.ParseForm(); err != nil {
+		return false
+	}
+
+	return twilio.ValidateSignature(
+		twilio.CallbackURL+c.Request.URL.RequestURI(),
+		c.Request.PostForm,
+		c.GetHeader("X-Twilio-Signature"),
+	)
+}
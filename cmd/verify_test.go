@@ -0,0 +1,50 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/rishabh-bector/BenevolentBitesBack/database"
+	"github.com/rishabh-bector/BenevolentBitesBack/twilio"
+)
+
+// TestVerifyRestaurantByPhone sends a code to the fixture restaurant's number through the fake
+// provider, and enters it like an owner would, with the sessions kept in Mongo
+func TestVerifyRestaurantByPhone(t *testing.T) {
+	router, cleanup := withTestServer(t)
+	defer cleanup()
+	router.GET("/rest/verifycall", MakeVerifyCall)
+	router.POST("/rest/verifycode", VerifyCode)
+
+	fake := &twilio.FakeProvider{}
+	defer twilio.SetProvider(twilio.SetProvider(fake))
+
+	if err := database.UpdateRestaurant(testOwner, database.Restaurant{Name: "Taqueria El Sol", PlaceID: testPlaceID}); err != nil {
+		t.Fatal(err)
+	}
+
+	if w := serve(router, "GET", "/rest/verifycall?method=sms", ""); w.Code != 200 {
+		t.Fatalf("sending code: %d %s", w.Code, w.Body.String())
+	}
+	code, ok := fake.LastCode("+15125550142")
+	if !ok {
+		t.Fatalf("expected a code sent to the number on Google Maps, got %+v", fake.Sent)
+	}
+
+	if w := serve(router, "POST", "/rest/verifycode", `{"code": "not it"}`); w.Code != 403 {
+		t.Errorf("expected a wrong code to be refused, got %d", w.Code)
+	}
+	if database.DoesRestaurantExist(testOwner).Verified {
+		t.Fatal("verified by a wrong code")
+	}
+
+	if w := serve(router, "POST", "/rest/verifycode", `{"code": "`+code+`"}`); w.Code != 200 {
+		t.Fatalf("verifying: %d %s", w.Code, w.Body.String())
+	}
+	if !database.DoesRestaurantExist(testOwner).Verified {
+		t.Error("expected the restaurant to be verified")
+	}
+
+	if w := serve(router, "POST", "/rest/verifycode", `{"code": "`+code+`"}`); w.Code != 403 {
+		t.Errorf("expected a used code to be refused, got %d", w.Code)
+	}
+}
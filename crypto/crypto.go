@@ -1,6 +1,12 @@
 package crypto
 
 import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
 	"errors"
 	"fmt"
 	"os"
@@ -53,3 +59,57 @@ func ValidateJWT(s string) (string, error) {
 	}
 	return "", fmt.Errorf("invalid card claims")
 }
+
+// EncryptString hides s from whoever sees the result, unlike SignString which only
+// stops it being changed. Like SignString, the result expires after an hour.
+func EncryptString(s string) (string, error) {
+	gcm, err := newGCM()
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	plain := make([]byte, 8, 8+len(s))
+	binary.BigEndian.PutUint64(plain, uint64(time.Now().Unix()+3600))
+	plain = append(plain, s...)
+
+	sealed := gcm.Seal(nonce, nonce, plain, nil)
+	return base64.RawURLEncoding.EncodeToString(sealed), nil
+}
+
+// DecryptString opens a string from EncryptString
+func DecryptString(s string) (string, error) {
+	gcm, err := newGCM()
+	if err != nil {
+		return "", err
+	}
+
+	sealed, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || len(sealed) < gcm.NonceSize() {
+		return "", errors.New("invalid encrypted string")
+	}
+
+	plain, err := gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], nil)
+	if err != nil || len(plain) < 8 {
+		return "", errors.New("invalid encrypted string")
+	}
+
+	if int64(binary.BigEndian.Uint64(plain)) < time.Now().Unix() {
+		return "", errors.New("encrypted string expired")
+	}
+	return string(plain[8:]), nil
+}
+
+// newGCM makes an AES-256 cipher from the server secret
+func newGCM() (cipher.AEAD, error) {
+	sum := sha256.Sum256(key)
+	block, err := aes.NewCipher(sum[:])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
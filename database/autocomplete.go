@@ -0,0 +1,110 @@
+package database
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// AutocompleteRestaurants finds published restaurants for partly typed text. Restaurants whose
+// name starts with the text come first, then those with a word matching it through the text index.
+func AutocompleteRestaurants(text string, limit int) ([]Restaurant, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	text = strings.TrimSpace(text)
+	results := []Restaurant{}
+	if text == "" || limit <= 0 {
+		return results, nil
+	}
+
+	visible := bson.M{"published": true, "archived": bson.M{"$ne": true}}
+
+	// A case sensitive prefix can walk the nameKey index, a case insensitive one would scan all of it
+	prefix := bson.M{"nameKey": primitive.Regex{Pattern: "^" + regexp.QuoteMeta(nameKey(text))}}
+	for k, v := range visible {
+		prefix[k] = v
+	}
+
+	cur, err := RestCollection.Find(ctx, prefix, options.Find().SetLimit(int64(limit)).SetSort(bson.M{"nameKey": 1}))
+	if err != nil {
+		return nil, err
+	}
+	if err := cur.All(ctx, &results); err != nil {
+		return nil, err
+	}
+	if len(results) >= limit {
+		return results, nil
+	}
+
+	words := bson.M{"$text": bson.M{"$search": text}}
+	for k, v := range visible {
+		words[k] = v
+	}
+
+	score := bson.M{"score": bson.M{"$meta": "textScore"}}
+	opts := options.Find().SetLimit(int64(limit)).SetProjection(score).SetSort(score)
+	cur, err = RestCollection.Find(ctx, words, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []Restaurant
+	if err := cur.All(ctx, &matches); err != nil {
+		return nil, err
+	}
+
+	for _, m := range matches {
+		if len(results) >= limit {
+			break
+		}
+
+		dup := false
+		for _, r := range results {
+			dup = dup || r.UUID == m.UUID
+		}
+		if !dup {
+			results = append(results, m)
+		}
+	}
+
+	return results, nil
+}
+
+// nameKey is how a name is stored for prefix matching
+func nameKey(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+// BackfillNameKeys sets the nameKey of restaurants saved before there was one
+func BackfillNameKeys() {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	filter := bson.M{"nameKey": bson.M{"$exists": false}}
+	cur, err := RestCollection.Find(ctx, filter, options.Find().SetProjection(bson.M{"uuid": 1, "name": 1}))
+	if err != nil {
+		log.Error("BB: unable to find restaurants without a name key: ", err)
+		return
+	}
+
+	var rests []Restaurant
+	if err := cur.All(ctx, &rests); err != nil {
+		log.Error("BB: unable to find restaurants without a name key: ", err)
+		return
+	}
+
+	for _, r := range rests {
+		update := bson.M{"$set": bson.M{"nameKey": nameKey(r.Name)}}
+		if _, err := RestCollection.UpdateOne(ctx, bson.M{"uuid": r.UUID}, update); err != nil {
+			log.Error("BB: unable to set name key of ", r.UUID, ": ", err)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/rishabh-bector/BenevolentBitesBack/auth"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Campaign is a fundraising push a restaurant runs for a while, e.g. to get through a closure.
+// Campaigns without an end run until the owner ends them.
+type Campaign struct {
+	ID          string     `bson:"id" json:"id"`
+	Title       string     `bson:"title" json:"title"`
+	Description string     `bson:"description" json:"description"`
+	Goal        int        `bson:"goal" json:"goal"` // Cents, 0 for no goal
+	Start       time.Time  `bson:"start" json:"start"`
+	End         *time.Time `bson:"end,omitempty" json:"end,omitempty"`
+	Created     time.Time  `bson:"created" json:"created"`
+}
+
+// ActiveAt reports whether the campaign is running at time t
+func (c Campaign) ActiveAt(t time.Time) bool {
+	return !c.Start.After(t) && (c.End == nil || t.Before(*c.End))
+}
+
+// ActiveCampaign returns the campaign running at time t, if any
+func (r *Restaurant) ActiveCampaign(t time.Time) *Campaign {
+	for i := len(r.Campaigns) - 1; i >= 0; i-- {
+		if r.Campaigns[i].ActiveAt(t) {
+			return &r.Campaigns[i]
+		}
+	}
+	return nil
+}
+
+// activeCampaignFilter matches restaurants with a campaign running at time t
+func activeCampaignFilter(t time.Time) bson.M {
+	return bson.M{"$elemMatch": bson.M{
+		"start": bson.M{"$lte": t},
+		"$or":   []bson.M{{"end": bson.M{"$exists": false}}, {"end": bson.M{"$gt": t}}},
+	}}
+}
+
+// AddRestaurantCampaign starts a new campaign. A restaurant can only run one campaign at a time.
+func AddRestaurantCampaign(owner string, c Campaign) (Campaign, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if c.Title == "" {
+		return c, errors.New("sorry bro, a campaign needs a title")
+	}
+	if c.Goal < 0 {
+		return c, errors.New("sorry bro, invalid campaign goal")
+	}
+	if c.Start.IsZero() {
+		c.Start = time.Now()
+	}
+	if c.End != nil && !c.End.After(c.Start) {
+		return c, errors.New("sorry bro, a campaign has to end after it starts")
+	}
+
+	r := DoesRestaurantExist(owner)
+	if r.Owner == "nil" {
+		return c, errors.New("sorry bro, that restaurant doesn't exist")
+	}
+	for _, other := range r.Campaigns {
+		if other.End == nil || other.End.After(c.Start) {
+			return c, errors.New("sorry bro, end your current campaign before starting a new one")
+		}
+	}
+
+	c.ID = auth.GenerateUUID()
+	c.Created = time.Now()
+
+	filter := bson.M{"owner": owner}
+	_, err := RestCollection.UpdateOne(ctx, filter, bson.M{"$push": bson.M{"campaigns": c}})
+	if err != nil {
+		return c, err
+	}
+
+	recordRestaurantVersion(filter, owner, "campaign")
+
+	return c, nil
+}
+
+// EndRestaurantCampaign ends a campaign now, or cancels it if it hasn't started yet
+func EndRestaurantCampaign(owner, id string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	r := DoesRestaurantExist(owner)
+	if r.Owner == "nil" {
+		return errors.New("sorry bro, that restaurant doesn't exist")
+	}
+
+	now := time.Now()
+	for _, c := range r.Campaigns {
+		if c.ID != id {
+			continue
+		}
+		if c.End != nil && !c.End.After(now) {
+			return errors.New("sorry bro, that campaign has already ended")
+		}
+
+		// A campaign which hasn't started yet ends before it starts, so it never runs
+		end := now
+		if c.Start.After(now) {
+			end = c.Start
+		}
+
+		filter := bson.M{"owner": owner, "campaigns.id": id}
+		_, err := RestCollection.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"campaigns.$.end": end}})
+		if err != nil {
+			return err
+		}
+
+		recordRestaurantVersion(bson.M{"owner": owner}, owner, "campaign")
+
+		return nil
+	}
+
+	return errors.New("sorry bro, that campaign doesn't exist")
+}
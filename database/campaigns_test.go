@@ -0,0 +1,78 @@
+package database
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCampaignActiveAt(t *testing.T) {
+	start := time.Date(2020, 4, 1, 0, 0, 0, 0, time.UTC)
+	end := start.Add(7 * 24 * time.Hour)
+
+	open := Campaign{Start: start}
+	ended := Campaign{Start: start, End: &end}
+
+	cases := []struct {
+		c    Campaign
+		t    time.Time
+		want bool
+	}{
+		{open, start.Add(-time.Second), false},
+		{open, start, true},
+		{open, end.Add(365 * 24 * time.Hour), true},
+		{ended, start.Add(time.Hour), true},
+		{ended, end, false},
+	}
+
+	for i, c := range cases {
+		if got := c.c.ActiveAt(c.t); got != c.want {
+			t.Errorf("case %d: expected %v, got %v", i, c.want, got)
+		}
+	}
+
+	r := Restaurant{Campaigns: []Campaign{ended, open}}
+	if got := r.ActiveCampaign(end); got == nil || got.End != nil {
+		t.Errorf("expected the open campaign to be active, got %+v", got)
+	}
+}
+
+// TestCampaignSearch starts and ends a campaign the way owners do, and checks the
+// hasCampaign partner search follows along
+func TestCampaignSearch(t *testing.T) {
+	defer withTestDB(t)()
+
+	owner := "owner@example.com"
+	if err := UpdateRestaurant(owner, Restaurant{Name: "Taqueria El Sol", Published: true}); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetRestaurantLocation(owner, 30.263, -97.731); err != nil {
+		t.Fatal(err)
+	}
+
+	search := func() int {
+		results, err := SearchPartners(PartnerQuery{Lat: 30.263, Lng: -97.731, MaxMeters: 1000, Campaign: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(results)
+	}
+
+	if n := search(); n != 0 {
+		t.Fatalf("expected no restaurants with a campaign, got %d", n)
+	}
+
+	c, err := AddRestaurantCampaign(owner, Campaign{Title: "Keep the lights on"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := search(); n != 1 {
+		t.Fatalf("expected the restaurant once its campaign started, got %d", n)
+	}
+
+	if err := EndRestaurantCampaign(owner, c.ID); err != nil {
+		t.Fatal(err)
+	}
+	if n := search(); n != 0 {
+		t.Fatalf("expected no restaurants once the campaign ended, got %d", n)
+	}
+}
@@ -26,8 +26,17 @@ type Transaction struct {
 	Amount    int    `bson:"amount" json:"amount"`
 	ID        string `bson:"id" json:"id"`
 	Signature string `bson:"signature" json:"signature"`
+	OrderID   string `bson:"orderId,omitempty" json:"orderId,omitempty"`
+	Type      string `bson:"type,omitempty" json:"type,omitempty"`
 }
 
+// Transaction types for credit which leaves a card without being redeemed
+const (
+	TransactionRefund       = "refund"
+	TransactionDonation     = "donation"
+	TransactionManualRefund = "manual-refund" // For cards without a Square order, an admin refunds these by hand
+)
+
 var NilCard = Card{UUID: "nil"}
 
 // CreateCard makes a new card with empty balance
@@ -125,6 +134,29 @@ func SubtractCredit(id string, amount int, signature string) error {
 	return nil
 }
 
+// SettleCard empties a card which will not be redeemed, recording how its balance was settled
+func SettleCard(id string, trans Transaction) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	trans.Timestamp = time.Now().Format(time.RFC3339)
+
+	filter := bson.M{"uuid": id, "balance": bson.M{"$gt": 0}}
+	update := bson.M{
+		"$set":  bson.M{"balance": 0},
+		"$push": bson.M{"transactions": trans},
+	}
+	res, err := CardCollection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return errors.New("sorry bro, that card has already been settled")
+	}
+
+	return nil
+}
+
 // GetUserCards retrieves all the cards which belong to a given user
 func GetUserCards(user string) ([]Card, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
@@ -0,0 +1,256 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Closure tracks a restaurant shutting down, and how each of its outstanding cards is settled
+type Closure struct {
+	RestUUID   string        `bson:"restaurant" json:"restaurant"`
+	RestName   string        `bson:"name" json:"name"`
+	Reason     string        `bson:"reason" json:"reason"`
+	StartedBy  string        `bson:"startedBy" json:"startedBy"`
+	StartedAt  time.Time     `bson:"startedAt" json:"startedAt"`
+	Status     string        `bson:"status" json:"status"`
+	ArchivedAt time.Time     `bson:"archivedAt" json:"archivedAt"`
+	Cards      []ClosureCard `bson:"cards" json:"cards"`
+}
+
+// ClosureCard is a single card which has to be settled before a restaurant is archived
+type ClosureCard struct {
+	Card       string    `bson:"card" json:"-"`
+	User       string    `bson:"user" json:"user"`
+	Balance    int       `bson:"balance" json:"balance"`
+	Notified   bool      `bson:"notified" json:"notified"`
+	Resolution string    `bson:"resolution" json:"resolution"`
+	ResolvedAt time.Time `bson:"resolvedAt" json:"resolvedAt"`
+	Error      string    `bson:"error" json:"error"`
+
+	// Set while a card is being settled, see ClaimClosureCard
+	Pending   string    `bson:"pending" json:"pending"`
+	PendingAt time.Time `bson:"pendingAt" json:"pendingAt"`
+}
+
+// ClosureClaimTimeout is how long a claimed card is left alone before it can be settled again,
+// in case whoever claimed it never finished
+const ClosureClaimTimeout = 5 * time.Minute
+
+// Closure statuses
+const (
+	ClosureSettling = "settling"
+	ClosureArchived = "archived"
+)
+
+// ClosureCardSpent resolves a card whose credit was used up before it was settled. Cards can
+// also be resolved by any of the transaction types for credit which leaves a card.
+const ClosureCardSpent = "spent"
+
+// ClosureProgress summarises a closure for admins
+type ClosureProgress struct {
+	Cards       int `json:"cards"`
+	Notified    int `json:"notified"`
+	Refunded    int `json:"refunded"`
+	Donated     int `json:"donated"`
+	Manual      int `json:"manual"` // Refunds an admin has to make by hand
+	Spent       int `json:"spent"`
+	Pending     int `json:"pending"`
+	Failed      int `json:"failed"`
+	Outstanding int `json:"outstanding"` // Credit not yet settled, in cents
+}
+
+// Progress counts how many of a closure's cards have been notified and settled
+func (cl *Closure) Progress() ClosureProgress {
+	var p ClosureProgress
+	for _, c := range cl.Cards {
+		p.Cards++
+		if c.Notified {
+			p.Notified++
+		}
+		switch c.Resolution {
+		case TransactionRefund:
+			p.Refunded++
+		case TransactionDonation:
+			p.Donated++
+		case TransactionManualRefund:
+			p.Manual++
+		case ClosureCardSpent:
+			p.Spent++
+		default:
+			p.Pending++
+			p.Outstanding += c.Balance
+			if c.Error != "" {
+				p.Failed++
+			}
+		}
+	}
+	return p
+}
+
+// StartClosure stops all sales for a restaurant and records every card which still holds credit
+func StartClosure(r Restaurant, admin, reason string) (Closure, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if r.Closing || r.Archived {
+		return Closure{}, errors.New("sorry bro, that restaurant is already closing")
+	}
+
+	// Stop sales first, so that no new cards appear while we look.
+	// Only one admin can flip closing, so only one closure is started.
+	filter := bson.M{"uuid": r.UUID, "closing": bson.M{"$ne": true}, "archived": bson.M{"$ne": true}}
+	update := bson.M{"$set": bson.M{"closing": true, "paused": true}}
+	res, err := RestCollection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return Closure{}, err
+	}
+	if res.MatchedCount == 0 {
+		return Closure{}, errors.New("sorry bro, that restaurant is already closing")
+	}
+	recordRestaurantVersion(bson.M{"uuid": r.UUID}, admin, "closure")
+
+	cards, err := GetRestaurantCards(r.UUID)
+	if err != nil {
+		return Closure{}, err
+	}
+
+	cl := Closure{
+		RestUUID:  r.UUID,
+		RestName:  r.Name,
+		Reason:    reason,
+		StartedBy: admin,
+		StartedAt: time.Now(),
+		Status:    ClosureSettling,
+		Cards:     []ClosureCard{},
+	}
+	for _, card := range cards {
+		if card.Balance <= 0 {
+			continue
+		}
+		cl.Cards = append(cl.Cards, ClosureCard{
+			Card:    card.UUID,
+			User:    card.User,
+			Balance: card.Balance,
+		})
+	}
+
+	_, err = ClosureCollection.InsertOne(ctx, cl)
+	if isDuplicateKeyError(err) {
+		return Closure{}, errors.New("sorry bro, that restaurant is already closing")
+	}
+	if err != nil {
+		return Closure{}, err
+	}
+
+	return cl, nil
+}
+
+// GetClosure finds the closure for a restaurant
+func GetClosure(restUUID string) (Closure, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var cl Closure
+	err := ClosureCollection.FindOne(ctx, bson.M{"restaurant": restUUID}).Decode(&cl)
+	if err == mongo.ErrNoDocuments {
+		return Closure{}, errors.New("sorry bro, that restaurant isn't closing")
+	}
+	return cl, err
+}
+
+// GetAllClosures returns every closure, most recent first
+func GetAllClosures() ([]Closure, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.M{"startedAt": -1})
+	cur, err := ClosureCollection.Find(ctx, bson.M{}, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	result := []Closure{}
+	err = cur.All(ctx, &result)
+	return result, err
+}
+
+// MarkClosureCardNotified records that a cardholder was emailed about the closure
+func MarkClosureCardNotified(restUUID, cardUUID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	filter := bson.M{"restaurant": restUUID, "cards.card": cardUUID}
+	update := bson.M{"$set": bson.M{"cards.$.notified": true}}
+	_, err := ClosureCollection.UpdateOne(ctx, filter, update)
+	return err
+}
+
+// ClaimClosureCard marks an unsettled card as being settled, so only one request settles it.
+// It reports false if the card is already settled, or someone else is settling it.
+func ClaimClosureCard(restUUID, cardUUID, choice string) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	filter := bson.M{
+		"restaurant": restUUID,
+		"cards": bson.M{"$elemMatch": bson.M{
+			"card":       cardUUID,
+			"resolution": "",
+			"$or": []bson.M{
+				{"pending": bson.M{"$in": []interface{}{"", nil}}},
+				{"pendingAt": bson.M{"$lt": now.Add(-ClosureClaimTimeout)}},
+			},
+		}},
+	}
+	update := bson.M{"$set": bson.M{"cards.$.pending": choice, "cards.$.pendingAt": now}}
+
+	res, err := ClosureCollection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+	return res.ModifiedCount == 1, nil
+}
+
+// ResolveClosureCard records how a card was settled, or why settling it failed, and releases its claim
+func ResolveClosureCard(restUUID, cardUUID, resolution string, failure error) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	set := bson.M{"cards.$.pending": ""}
+	if failure != nil {
+		set["cards.$.error"] = failure.Error()
+	} else {
+		set["cards.$.resolution"] = resolution
+		set["cards.$.resolvedAt"] = time.Now()
+		set["cards.$.error"] = ""
+	}
+
+	filter := bson.M{"restaurant": restUUID, "cards.card": cardUUID}
+	_, err := ClosureCollection.UpdateOne(ctx, filter, bson.M{"$set": set})
+	return err
+}
+
+// ArchiveRestaurant hides a closed restaurant for good and completes its closure
+func ArchiveRestaurant(restUUID, actor string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	filter := bson.M{"uuid": restUUID}
+	update := bson.M{"$set": bson.M{"archived": true, "closing": false, "published": false}}
+	_, err := RestCollection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+	recordRestaurantVersion(filter, actor, "archive")
+
+	filter = bson.M{"restaurant": restUUID}
+	update = bson.M{"$set": bson.M{"status": ClosureArchived, "archivedAt": time.Now()}}
+	_, err = ClosureCollection.UpdateOne(ctx, filter, update)
+	return err
+}
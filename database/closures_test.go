@@ -0,0 +1,29 @@
+package database
+
+import "testing"
+
+func TestClosureProgress(t *testing.T) {
+	cl := Closure{Cards: []ClosureCard{
+		{Balance: 500, Resolution: TransactionRefund, Notified: true},
+		{Balance: 700, Resolution: TransactionDonation},
+		{Balance: 900, Resolution: TransactionManualRefund},
+		{Balance: 300, Resolution: ClosureCardSpent},
+		{Balance: 1100, Error: "square is down"},
+		{Balance: 1300},
+	}}
+
+	want := ClosureProgress{
+		Cards:       6,
+		Notified:    1,
+		Refunded:    1,
+		Donated:     1,
+		Manual:      1,
+		Spent:       1,
+		Pending:     2,
+		Failed:      1,
+		Outstanding: 2400,
+	}
+	if p := cl.Progress(); p != want {
+		t.Errorf("expected %+v, got %+v", want, p)
+	}
+}
@@ -0,0 +1,142 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// GeoPoint is a GeoJSON point, as required by Mongo's 2dsphere index.
+// Coordinates are [longitude, latitude].
+type GeoPoint struct {
+	Type        string    `bson:"type" json:"type"`
+	Coordinates []float64 `bson:"coordinates" json:"coordinates"`
+}
+
+// NewGeoPoint creates a point from a latitude and longitude
+func NewGeoPoint(lat, lng float64) *GeoPoint {
+	return &GeoPoint{Type: "Point", Coordinates: []float64{lng, lat}}
+}
+
+// Lat returns the latitude of the point
+func (p *GeoPoint) Lat() float64 {
+	if p == nil || len(p.Coordinates) != 2 {
+		return 0
+	}
+	return p.Coordinates[1]
+}
+
+// Lng returns the longitude of the point
+func (p *GeoPoint) Lng() float64 {
+	if p == nil || len(p.Coordinates) != 2 {
+		return 0
+	}
+	return p.Coordinates[0]
+}
+
+// PartnerQuery describes a search for partner restaurants around a point
+type PartnerQuery struct {
+	Lat, Lng  float64
+	MaxMeters float64
+	Cuisines  []string
+	Dietary   []string
+	Campaign  bool // Only restaurants running a campaign
+	Skip      int
+	Limit     int
+}
+
+// PartnerResult is a restaurant found by SearchPartners, with its distance from the search point
+type PartnerResult struct {
+	Restaurant `bson:",inline"`
+	Distance   float64 `bson:"distance"`
+}
+
+// SearchPartners finds published restaurants around a point, nearest first.
+// This doesn't depend on Google ranking the restaurant for a query.
+func SearchPartners(q PartnerQuery) ([]PartnerResult, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if q.Lat < -90 || q.Lat > 90 || q.Lng < -180 || q.Lng > 180 {
+		return nil, errors.New("sorry bro, invalid coordinates")
+	}
+
+	filter := bson.M{"published": true, "archived": bson.M{"$ne": true}}
+	if c := normalizeTags(q.Cuisines); len(c) > 0 {
+		filter["cuisines"] = bson.M{"$all": c}
+	}
+	if d := normalizeTags(q.Dietary); len(d) > 0 {
+		filter["dietary"] = bson.M{"$all": d}
+	}
+	if q.Campaign {
+		filter["campaigns"] = activeCampaignFilter(time.Now())
+	}
+
+	geoNear := bson.M{
+		"near":          NewGeoPoint(q.Lat, q.Lng),
+		"distanceField": "distance",
+		"spherical":     true,
+		"query":         filter,
+	}
+	if q.MaxMeters > 0 {
+		geoNear["maxDistance"] = q.MaxMeters
+	}
+
+	pipeline := []bson.M{{"$geoNear": geoNear}}
+	if q.Skip > 0 {
+		pipeline = append(pipeline, bson.M{"$skip": q.Skip})
+	}
+	if q.Limit > 0 {
+		pipeline = append(pipeline, bson.M{"$limit": q.Limit})
+	}
+
+	cur, err := RestCollection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+
+	results := []PartnerResult{}
+	if err := cur.All(ctx, &results); err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// SetRestaurantLocation stores where a restaurant is, so it can be found by SearchPartners
+func SetRestaurantLocation(owner string, lat, lng float64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	res, err := RestCollection.UpdateOne(ctx, bson.M{"owner": owner}, bson.M{"$set": bson.M{"location": NewGeoPoint(lat, lng)}})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+
+	return nil
+}
+
+// GetUnlocatedRestaurants returns every restaurant with a place ID but no stored location
+func GetUnlocatedRestaurants() ([]Restaurant, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	filter := bson.M{"location": bson.M{"$exists": false}, "placeId": bson.M{"$gt": ""}}
+	cur, err := RestCollection.Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	var rests []Restaurant
+	if err := cur.All(ctx, &rests); err != nil {
+		return nil, err
+	}
+
+	return rests, nil
+}
@@ -0,0 +1,196 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/rishabh-bector/BenevolentBitesBack/auth"
+	log "github.com/sirupsen/logrus"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// RestaurantVersion is a snapshot of a restaurant, taken every time it changes
+type RestaurantVersion struct {
+	RestUUID  string     `bson:"restaurant" json:"restaurant"`
+	Version   int        `bson:"version" json:"version"`
+	Actor     string     `bson:"actor" json:"actor"`
+	Action    string     `bson:"action" json:"action"`
+	Timestamp time.Time  `bson:"timestamp" json:"timestamp"`
+	Changed   []string   `bson:"changed" json:"changed"`
+	Snapshot  Restaurant `bson:"snapshot" json:"snapshot"`
+}
+
+// AdminFields are the restaurant fields only admins may see, see OwnerHistory
+var AdminFields = []string{"splits", "maxOutstanding", "maxCardBalance", "closing", "archived"}
+
+// OwnerRestaurantVersion is a RestaurantVersion as shown to the restaurant's owner
+type OwnerRestaurantVersion struct {
+	RestaurantVersion
+	Snapshot map[string]interface{} `json:"snapshot"`
+}
+
+// OwnerHistory hides the admin fields from a restaurant's history. Versions which only
+// changed admin fields are left out altogether.
+func OwnerHistory(versions []RestaurantVersion) []OwnerRestaurantVersion {
+	out := []OwnerRestaurantVersion{}
+	for _, v := range versions {
+		changed := []string{}
+		for _, f := range v.Changed {
+			if !stringInSlice(f, AdminFields) {
+				changed = append(changed, f)
+			}
+		}
+		if len(v.Changed) > 0 && len(changed) == 0 {
+			continue
+		}
+
+		snapshot := ConvertRestToMap(v.Snapshot)
+		for _, f := range AdminFields {
+			delete(snapshot, f)
+		}
+
+		v.Changed = changed
+		v.Snapshot = Restaurant{}
+		out = append(out, OwnerRestaurantVersion{RestaurantVersion: v, Snapshot: snapshot})
+	}
+	return out
+}
+
+// Redacted returns a copy of the restaurant without any secrets, safe to keep in its history
+func (r Restaurant) Redacted() Restaurant {
+	r.PassHash = ""
+	r.Square = auth.SquareAuth{
+		MerchantID: r.Square.MerchantID,
+		LocationID: r.Square.LocationID,
+	}
+	return r
+}
+
+// recordRestaurantVersion snapshots the restaurant matching filter after a change.
+// Failing to record history never fails the change itself.
+func recordRestaurantVersion(filter bson.M, actor, action string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var r Restaurant
+	err := RestCollection.FindOne(ctx, filter).Decode(&r)
+	if err != nil {
+		log.Error("BB: unable to find restaurant for history: ", err)
+		return
+	}
+	r = r.Redacted()
+
+	latest, err := GetLatestRestaurantVersion(r.UUID)
+	if err != nil && err != mongo.ErrNoDocuments {
+		log.Error("BB: unable to find restaurant history: ", err)
+		return
+	}
+
+	changed := changedRestaurantFields(latest.Snapshot, r)
+	if latest.Version > 0 && len(changed) == 0 {
+		return
+	}
+
+	v := RestaurantVersion{
+		RestUUID:  r.UUID,
+		Version:   latest.Version + 1,
+		Actor:     actor,
+		Action:    action,
+		Timestamp: time.Now(),
+		Changed:   changed,
+		Snapshot:  r,
+	}
+	_, err = HistoryCollection.InsertOne(ctx, v)
+	if err != nil {
+		log.Error("BB: unable to record restaurant history: ", err)
+	}
+}
+
+// changedRestaurantFields lists the top level fields which differ between two restaurants
+func changedRestaurantFields(a, b Restaurant) []string {
+	am := ConvertRestToMap(a)
+	bm := ConvertRestToMap(b)
+
+	changed := []string{}
+	for k, v := range bm {
+		if !reflect.DeepEqual(am[k], v) {
+			changed = append(changed, k)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+// GetLatestRestaurantVersion returns the most recent snapshot of a restaurant
+func GetLatestRestaurantVersion(restUUID string) (RestaurantVersion, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	opts := options.FindOne().SetSort(bson.M{"version": -1})
+	var v RestaurantVersion
+	err := HistoryCollection.FindOne(ctx, bson.M{"restaurant": restUUID}, opts).Decode(&v)
+	return v, err
+}
+
+// GetRestaurantVersions returns the history of a restaurant, most recent first
+func GetRestaurantVersions(restUUID string) ([]RestaurantVersion, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.M{"version": -1})
+	cur, err := HistoryCollection.Find(ctx, bson.M{"restaurant": restUUID}, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	result := []RestaurantVersion{}
+	err = cur.All(ctx, &result)
+	return result, err
+}
+
+// GetRestaurantVersion returns a single snapshot of a restaurant
+func GetRestaurantVersion(restUUID string, version int) (RestaurantVersion, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var v RestaurantVersion
+	err := HistoryCollection.FindOne(ctx, bson.M{"restaurant": restUUID, "version": version}).Decode(&v)
+	if err == mongo.ErrNoDocuments {
+		return v, fmt.Errorf("sorry bro, version %d doesn't exist", version)
+	}
+	return v, err
+}
+
+// RestoreRestaurantVersion puts the owner editable fields of an earlier version back in place
+func RestoreRestaurantVersion(restUUID string, version int, actor string) error {
+	v, err := GetRestaurantVersion(restUUID, version)
+	if err != nil {
+		return err
+	}
+
+	r := DoesRestaurantExistUUID(restUUID)
+	if r.Owner == "nil" {
+		return errors.New("sorry bro, that restaurant doesn't exist")
+	}
+
+	restored := ApplyOwnerFields(r, v.Snapshot)
+	restored.PlaceID = v.Snapshot.PlaceID
+
+	fields := []string{"placeId"}
+	if v.Snapshot.Location != nil {
+		restored.Location = v.Snapshot.Location
+		fields = append(fields, "location")
+	}
+	for k := range OwnerEditableFields {
+		fields = append(fields, k)
+	}
+
+	return SaveRestaurantFields(r.Owner, actor, fmt.Sprintf("restore %d", version), restored, fields)
+}
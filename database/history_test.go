@@ -0,0 +1,37 @@
+package database
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestOwnerHistory(t *testing.T) {
+	snapshot := Restaurant{Name: "Taqueria El Sol", MaxOutstanding: 5000, Splits: []SplitPolicy{{}}}
+	versions := []RestaurantVersion{
+		{Version: 3, Action: "caps", Changed: []string{"maxOutstanding", "splits"}, Snapshot: snapshot},
+		{Version: 2, Action: "update", Changed: []string{"maxCardBalance", "name"}, Snapshot: snapshot},
+		{Version: 1, Action: "create", Changed: []string{}, Snapshot: snapshot},
+	}
+
+	owner := OwnerHistory(versions)
+	if len(owner) != 2 || owner[0].Version != 2 || owner[1].Version != 1 {
+		t.Fatalf("expected the admin only version to be left out, got %+v", owner)
+	}
+	if len(owner[0].Changed) != 1 || owner[0].Changed[0] != "name" {
+		t.Errorf("expected only the name to show as changed, got %v", owner[0].Changed)
+	}
+	if owner[0].Snapshot["name"] != "Taqueria El Sol" {
+		t.Errorf("expected the snapshot to keep the name, got %v", owner[0].Snapshot["name"])
+	}
+
+	out, err := json.Marshal(owner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range AdminFields {
+		if strings.Contains(string(out), `"`+f+`"`) {
+			t.Errorf("expected %s to be hidden from owners, got %s", f, out)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package database
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ensureIndexes creates the indexes every collection relies on. Creating an index
+// which already exists does nothing, so this runs on every startup.
+func ensureIndexes() {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	indexes := map[*mongo.Collection][]mongo.IndexModel{
+		RestCollection: {
+			{
+				Keys: bson.D{{Key: "slug", Value: 1}},
+				Options: options.Index().SetUnique(true).
+					SetPartialFilterExpression(bson.M{"slug": bson.M{"$type": "string", "$gt": ""}}),
+			},
+			{Keys: bson.D{{Key: "previousSlugs", Value: 1}}},
+			{Keys: bson.D{{Key: "location", Value: "2dsphere"}}},
+			{Keys: bson.D{{Key: "name", Value: "text"}}},
+			{Keys: bson.D{{Key: "nameKey", Value: 1}}},
+			{
+				Keys: bson.D{{Key: "placeId", Value: 1}},
+				Options: options.Index().SetUnique(true).SetName(placeIDIndex).
+					SetPartialFilterExpression(bson.M{"placeId": bson.M{"$type": "string", "$gt": ""}}),
+			},
+		},
+		HistoryCollection: {
+			{
+				Keys:    bson.D{{Key: "restaurant", Value: 1}, {Key: "version", Value: -1}},
+				Options: options.Index().SetUnique(true),
+			},
+		},
+		UserCollection: {
+			{
+				Keys:    bson.D{{Key: "email", Value: 1}},
+				Options: options.Index().SetUnique(true),
+			},
+			{Keys: bson.D{{Key: "following", Value: 1}}},
+			{Keys: bson.D{{Key: "favorites", Value: 1}}},
+		},
+		NomCollection: {
+			{
+				Keys:    bson.D{{Key: "placeId", Value: 1}},
+				Options: options.Index().SetUnique(true),
+			},
+			{Keys: bson.D{{Key: "status", Value: 1}, {Key: "votes", Value: -1}}},
+		},
+		PlacesCollection: {
+			{
+				Keys:    bson.D{{Key: "key", Value: 1}},
+				Options: options.Index().SetUnique(true),
+			},
+			{Keys: bson.D{{Key: "placeId", Value: 1}}},
+			{
+				Keys:    bson.D{{Key: "expires", Value: 1}},
+				Options: options.Index().SetExpireAfterSeconds(0),
+			},
+		},
+		ClosureCollection: {
+			{
+				Keys:    bson.D{{Key: "restaurant", Value: 1}},
+				Options: options.Index().SetUnique(true),
+			},
+		},
+		VerifyCollection: {
+			{
+				Keys:    bson.D{{Key: "email", Value: 1}},
+				Options: options.Index().SetUnique(true),
+			},
+			{Keys: bson.D{{Key: "sid", Value: 1}}},
+			{
+				Keys:    bson.D{{Key: "expires", Value: 1}},
+				Options: options.Index().SetExpireAfterSeconds(0),
+			},
+		},
+	}
+
+	// Indexes are created one at a time, so existing data breaking one index doesn't stop the others
+	for coll, models := range indexes {
+		for _, model := range models {
+			_, err := coll.Indexes().CreateOne(ctx, model)
+			if err != nil {
+				log.Error("BB: unable to create index on ", coll.Name(), ": ", err)
+			}
+		}
+	}
+
+	BackfillNameKeys()
+
+	// The place ID index can't be created while two restaurants share a place,
+	// so say which ones have to be fixed
+	dups, err := FindDuplicatePlaceIDs()
+	if err != nil {
+		log.Error("BB: unable to look for duplicate place IDs: ", err)
+	}
+	for _, d := range dups {
+		log.Error("BB: place ", d.PlaceID, " is claimed by more than one restaurant, ",
+			"so place IDs aren't unique until it's fixed: ", strings.Join(d.Owners, ", "))
+	}
+}
+
+// isDuplicateKeyCommandError is isDuplicateKeyError for commands like findAndModify,
+// which report the error as a command error rather than a write exception
+func isDuplicateKeyCommandError(err error) bool {
+	ce, ok := err.(mongo.CommandError)
+	return ok && ce.Code == 11000
+}
+
+// isDuplicateKeyError reports whether a write failed because of a unique index
+func isDuplicateKeyError(err error) bool {
+	if we, ok := err.(mongo.WriteException); ok {
+		for _, e := range we.WriteErrors {
+			if e.Code == 11000 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isDuplicateKeyOn is isDuplicateKeyError for one particular index, by name
+func isDuplicateKeyOn(err error, index string) bool {
+	if we, ok := err.(mongo.WriteException); ok {
+		for _, e := range we.WriteErrors {
+			if e.Code == 11000 && strings.Contains(e.Message, "index: "+index+" ") {
+				return true
+			}
+		}
+	}
+	return false
+}
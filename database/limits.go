@@ -0,0 +1,129 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// SetRestaurantPaused stops or resumes new credit sales for a restaurant,
+// without hiding it from search
+func SetRestaurantPaused(owner string, paused bool) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	filter := bson.M{"owner": owner}
+	update := bson.M{"$set": bson.M{"paused": paused}}
+	res, err := RestCollection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return errors.New("sorry bro, that restaurant doesn't exist")
+	}
+
+	recordRestaurantVersion(filter, owner, "pause")
+
+	return nil
+}
+
+// SetRestaurantCaps limits the total outstanding credit of a restaurant, and
+// the credit any single user may hold with it. Amounts are in cents, 0 means no cap.
+func SetRestaurantCaps(uuid, admin string, maxOutstanding, maxCardBalance int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if maxOutstanding < 0 || maxCardBalance < 0 {
+		return errors.New("sorry bro, caps cannot be negative")
+	}
+
+	filter := bson.M{"uuid": uuid}
+	update := bson.M{"$set": bson.M{
+		"maxOutstanding": maxOutstanding,
+		"maxCardBalance": maxCardBalance,
+	}}
+	res, err := RestCollection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return errors.New("sorry bro, that restaurant doesn't exist")
+	}
+
+	recordRestaurantVersion(filter, admin, "caps")
+
+	return nil
+}
+
+// GetOutstandingBalance sums the balance of every card matching the filter
+func GetOutstandingBalance(filter bson.M) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	pipeline := []bson.M{
+		{"$match": filter},
+		{"$group": bson.M{"_id": nil, "total": bson.M{"$sum": "$balance"}}},
+	}
+	cur, err := CardCollection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return 0, err
+	}
+	defer cur.Close(ctx)
+
+	var result struct {
+		Total int `bson:"total"`
+	}
+	if cur.Next(ctx) {
+		if err := cur.Decode(&result); err != nil {
+			return 0, err
+		}
+	}
+
+	return result.Total, nil
+}
+
+// CanSell makes sure that a restaurant is able to sell the given amount of
+// credit to a user, explaining why not otherwise
+func CanSell(r *Restaurant, user string, amount int) error {
+	if r.Closing || r.Archived {
+		return fmt.Errorf("sorry bro, %s is closing down and no longer sells credit", r.Name)
+	}
+
+	if r.Paused {
+		return fmt.Errorf("sorry bro, %s has paused credit sales for now", r.Name)
+	}
+
+	if r.MaxOutstanding > 0 {
+		outstanding, err := GetOutstandingBalance(bson.M{"restaurant": r.UUID})
+		if err != nil {
+			return err
+		}
+		if outstanding+amount > r.MaxOutstanding {
+			return fmt.Errorf("sorry bro, %s can only have $%.2f of credit outstanding, so at most $%.2f more can be bought right now",
+				r.Name, float32(r.MaxOutstanding)/100, float32(max0(r.MaxOutstanding-outstanding))/100)
+		}
+	}
+
+	if r.MaxCardBalance > 0 {
+		balance, err := GetOutstandingBalance(bson.M{"restaurant": r.UUID, "user": user})
+		if err != nil {
+			return err
+		}
+		if balance+amount > r.MaxCardBalance {
+			return fmt.Errorf("sorry bro, you can hold at most $%.2f of credit at %s, so at most $%.2f more can be bought",
+				float32(r.MaxCardBalance)/100, r.Name, float32(max0(r.MaxCardBalance-balance))/100)
+		}
+	}
+
+	return nil
+}
+
+func max0(a int) int {
+	if a < 0 {
+		return 0
+	}
+	return a
+}
@@ -12,10 +12,15 @@ import (
 )
 
 var (
-	Client         *mongo.Client
-	RestCollection *mongo.Collection
-	UserCollection *mongo.Collection
-	CardCollection *mongo.Collection
+	Client            *mongo.Client
+	RestCollection    *mongo.Collection
+	UserCollection    *mongo.Collection
+	CardCollection    *mongo.Collection
+	ClosureCollection *mongo.Collection
+	HistoryCollection *mongo.Collection
+	PlacesCollection  *mongo.Collection
+	NomCollection     *mongo.Collection
+	VerifyCollection  *mongo.Collection
 )
 
 // Initialize connects to the Mongo cluster
@@ -35,12 +40,19 @@ func Initialize() {
 	RestCollection = Client.Database(os.Getenv("M_DB")).Collection("restaurants")
 	UserCollection = Client.Database(os.Getenv("M_DB")).Collection("users")
 	CardCollection = Client.Database(os.Getenv("M_DB")).Collection("cards")
+	ClosureCollection = Client.Database(os.Getenv("M_DB")).Collection("closures")
+	HistoryCollection = Client.Database(os.Getenv("M_DB")).Collection("restaurant_history")
+	PlacesCollection = Client.Database(os.Getenv("M_DB")).Collection("places_cache")
+	NomCollection = Client.Database(os.Getenv("M_DB")).Collection("nominations")
+	VerifyCollection = Client.Database(os.Getenv("M_DB")).Collection("verifications")
 
 	err = Client.Ping(ctx, nil)
 	if err != nil {
 		log.Error(err)
 	}
 
+	ensureIndexes()
+
 	log.Info("BB: Connected to the following databases:")
 	log.Info(Client.ListDatabaseNames(ctx, bson.D{}))
 }
@@ -0,0 +1,28 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+)
+
+// withTestDB connects to a scratch database on the Mongo at M_URL, with the same indexes as
+// production, and returns a func to drop it again. Without M_URL the test is skipped.
+func withTestDB(tb testing.TB) func() {
+	if os.Getenv("M_URL") == "" {
+		tb.Skip("M_URL isn't set")
+	}
+
+	old := os.Getenv("M_DB")
+	os.Setenv("M_DB", fmt.Sprintf("bb_test_%d", time.Now().UnixNano()))
+	Initialize()
+	db := Client.Database(os.Getenv("M_DB"))
+	os.Setenv("M_DB", old)
+
+	return func() {
+		db.Drop(context.Background())
+		Client.Disconnect(context.Background())
+	}
+}
@@ -0,0 +1,100 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Nomination collects the users asking for a restaurant which isn't on Benevolent Bites yet
+type Nomination struct {
+	PlaceID  string    `bson:"placeId" json:"placeId"`
+	Name     string    `bson:"name" json:"name"`
+	Address  string    `bson:"address" json:"address"`
+	Voters   []string  `bson:"voters" json:"-"`
+	Votes    int       `bson:"votes" json:"votes"`
+	FirstAt  time.Time `bson:"firstAt" json:"firstAt"`
+	LastAt   time.Time `bson:"lastAt" json:"lastAt"`
+	Status   string    `bson:"status" json:"status"`
+	RestUUID string    `bson:"restaurant,omitempty" json:"restaurant,omitempty"`
+	JoinedAt time.Time `bson:"joinedAt,omitempty" json:"joinedAt,omitempty"`
+}
+
+// Nomination statuses
+const (
+	NominationOpen   = "open"
+	NominationJoined = "joined"
+)
+
+// ErrAlreadyNominated is returned when a user nominates the same place twice
+var ErrAlreadyNominated = errors.New("sorry bro, you've already nominated that restaurant")
+
+// NominateRestaurant records a user's vote for a place. Each user only counts once per place.
+func NominateRestaurant(placeID, name, address, user string) (Nomination, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	now := time.Now()
+
+	// If the user already voted, the filter doesn't match and the upsert runs into the unique placeId index
+	filter := bson.M{"placeId": placeID, "voters": bson.M{"$ne": user}}
+	update := bson.M{
+		"$addToSet":    bson.M{"voters": user},
+		"$inc":         bson.M{"votes": 1},
+		"$set":         bson.M{"name": name, "address": address, "lastAt": now},
+		"$setOnInsert": bson.M{"firstAt": now, "status": NominationOpen},
+	}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+
+	var nom Nomination
+	err := NomCollection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&nom)
+	if isDuplicateKeyError(err) || isDuplicateKeyCommandError(err) {
+		return nom, ErrAlreadyNominated
+	}
+
+	return nom, err
+}
+
+// GetTopNominations returns the open nominations with the most votes
+func GetTopNominations(limit int) ([]Nomination, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.D{{Key: "votes", Value: -1}, {Key: "lastAt", Value: -1}}).SetLimit(int64(limit))
+	cur, err := NomCollection.Find(ctx, bson.M{"status": NominationOpen}, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	noms := []Nomination{}
+	if err := cur.All(ctx, &noms); err != nil {
+		return nil, err
+	}
+
+	return noms, nil
+}
+
+// ClaimNomination marks a place's nomination as joined by a restaurant, returning it so its
+// voters can be told. This only succeeds once, so nobody is told twice.
+func ClaimNomination(placeID, restUUID string) (Nomination, bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	filter := bson.M{"placeId": placeID, "status": NominationOpen}
+	update := bson.M{"$set": bson.M{"status": NominationJoined, "restaurant": restUUID, "joinedAt": time.Now()}}
+
+	var nom Nomination
+	err := NomCollection.FindOneAndUpdate(ctx, filter, update).Decode(&nom)
+	if err == mongo.ErrNoDocuments {
+		return nom, false, nil
+	}
+	if err != nil {
+		return nom, false, err
+	}
+
+	return nom, true, nil
+}
@@ -0,0 +1,159 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// OwnerEditableFields are the restaurant fields which an owner may change directly.
+// Everything else is set by verification, publishing, Square, or an admin.
+var OwnerEditableFields = map[string]bool{
+	"contact":     true,
+	"name":        true,
+	"address":     true,
+	"city":        true,
+	"state":       true,
+	"zip":         true,
+	"website":     true,
+	"yelp":        true,
+	"description": true,
+	"employees":   true,
+	"hours":       true,
+	"holidays":    true,
+	"timezone":    true,
+	"cuisines":    true,
+	"dietary":     true,
+	"instagram":   true,
+	"ordering":    true,
+	"story":       true,
+}
+
+// LocationFields are the owner editable fields which determine a restaurant's Place ID
+var LocationFields = []string{"name", "address", "city", "state", "zip"}
+
+// ApplyMergePatch applies an RFC 7396 JSON merge patch to a decoded JSON document
+func ApplyMergePatch(target, patch interface{}) interface{} {
+	p, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	t, ok := target.(map[string]interface{})
+	if !ok {
+		t = map[string]interface{}{}
+	}
+
+	for k, v := range p {
+		if v == nil {
+			delete(t, k)
+		} else {
+			t[k] = ApplyMergePatch(t[k], v)
+		}
+	}
+
+	return t
+}
+
+// MergePatchRestaurant applies an owner's merge patch to a restaurant. Only owner
+// editable fields may appear in the patch. The patched restaurant is returned
+// together with the names of the fields which were touched.
+func MergePatchRestaurant(r Restaurant, patch map[string]interface{}) (Restaurant, []string, error) {
+	var rejected []string
+	var fields []string
+	for k := range patch {
+		if !OwnerEditableFields[k] {
+			rejected = append(rejected, k)
+		}
+		fields = append(fields, k)
+	}
+	if len(rejected) > 0 {
+		sort.Strings(rejected)
+		return r, nil, fmt.Errorf("sorry bro, these fields can't be changed here: %s", strings.Join(rejected, ", "))
+	}
+	sort.Strings(fields)
+
+	// Only patch the editable part of the document, so nothing else can leak in
+	doc := map[string]interface{}{}
+	full := ConvertRestToMap(r)
+	for k := range OwnerEditableFields {
+		if v, ok := full[k]; ok {
+			doc[k] = v
+		}
+	}
+	doc = ApplyMergePatch(doc, patch).(map[string]interface{})
+
+	var edits Restaurant
+	m, err := json.Marshal(doc)
+	if err != nil {
+		return r, nil, err
+	}
+	if err := json.Unmarshal(m, &edits); err != nil {
+		return r, nil, fmt.Errorf("sorry bro, invalid restaurant field: %s", err.Error())
+	}
+	if err := edits.NormalizeProfile(); err != nil {
+		return r, nil, err
+	}
+
+	return ApplyOwnerFields(r, edits), fields, nil
+}
+
+// ApplyOwnerFields copies the owner editable fields of edits onto base,
+// leaving every protected field of base as it is
+func ApplyOwnerFields(base, edits Restaurant) Restaurant {
+	out := ConvertRestToMap(base)
+	em := ConvertRestToMap(edits)
+	for k := range OwnerEditableFields {
+		out[k] = em[k]
+	}
+	return ConvertMapToRest(out)
+}
+
+// SaveRestaurantFields writes only the given top level fields of a restaurant
+func SaveRestaurantFields(owner, actor, action string, r Restaurant, fields []string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if len(fields) == 0 {
+		return nil
+	}
+
+	raw, err := bson.Marshal(r)
+	if err != nil {
+		return err
+	}
+	var doc bson.M
+	if err := bson.Unmarshal(raw, &doc); err != nil {
+		return err
+	}
+
+	set := bson.M{}
+	for _, f := range fields {
+		set[f] = doc[f]
+		if f == "name" {
+			set["nameKey"] = nameKey(r.Name)
+		}
+	}
+
+	filter := bson.M{"owner": owner}
+	res, err := RestCollection.UpdateOne(ctx, filter, bson.M{"$set": set})
+	if isDuplicateKeyOn(err, placeIDIndex) {
+		return ErrPlaceTaken
+	}
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return errors.New("sorry bro, that restaurant doesn't exist")
+	}
+
+	recordRestaurantVersion(filter, actor, action)
+
+	return nil
+}
@@ -0,0 +1,146 @@
+package database
+
+import (
+	"crypto/sha1"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+)
+
+// Photo is a restaurant photo uploaded by its owner
+type Photo struct {
+	ID       string `bson:"id" json:"id"`
+	URL      string `bson:"url" json:"url"`
+	ThumbURL string `bson:"thumbUrl" json:"thumbUrl"`
+	Caption  string `bson:"caption" json:"caption"`
+	Alt      string `bson:"alt" json:"alt"`
+	Order    int    `bson:"order" json:"order"`
+	Cover    bool   `bson:"cover" json:"cover"`
+}
+
+// photoAlias has Photo's fields without its custom decoding
+type photoAlias Photo
+
+// legacyPhoto turns a photo stored as a bare url, before photos had records, into a Photo
+func legacyPhoto(url string) Photo {
+	return Photo{
+		ID:       fmt.Sprintf("legacy-%x", sha1.Sum([]byte(url)))[:23],
+		URL:      url,
+		ThumbURL: url,
+	}
+}
+
+// IsLegacy reports whether the photo was uploaded before photos had records
+func (p Photo) IsLegacy() bool {
+	return len(p.ID) > 7 && p.ID[:7] == "legacy-"
+}
+
+// UnmarshalBSONValue decodes a photo record, or a legacy photo url
+func (p *Photo) UnmarshalBSONValue(t bsontype.Type, data []byte) error {
+	if t == bsontype.String {
+		url, ok := bson.RawValue{Type: t, Value: data}.StringValueOK()
+		if !ok {
+			return errors.New("invalid legacy photo")
+		}
+		*p = legacyPhoto(url)
+		return nil
+	}
+	return bson.Unmarshal(data, (*photoAlias)(p))
+}
+
+// UnmarshalJSON decodes a photo record, or a legacy photo url
+func (p *Photo) UnmarshalJSON(data []byte) error {
+	var url string
+	if err := json.Unmarshal(data, &url); err == nil {
+		*p = legacyPhoto(url)
+		return nil
+	}
+	return json.Unmarshal(data, (*photoAlias)(p))
+}
+
+// SortedPhotos returns the restaurant's photos in display order, with the cover photo marked.
+// If the owner hasn't picked a cover, the first photo is used.
+func (r *Restaurant) SortedPhotos() []Photo {
+	out := make([]Photo, len(r.Photos))
+	copy(out, r.Photos)
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Order < out[j].Order })
+
+	hasCover := false
+	for i := range out {
+		out[i].Order = i
+		if out[i].Cover {
+			if hasCover {
+				out[i].Cover = false
+			}
+			hasCover = true
+		}
+	}
+	if !hasCover && len(out) > 0 {
+		out[0].Cover = true
+	}
+
+	return out
+}
+
+// PhotoURLs lists the display urls of the restaurant's photos, in display order
+func (r *Restaurant) PhotoURLs() []string {
+	urls := []string{}
+	for _, p := range r.SortedPhotos() {
+		urls = append(urls, p.URL)
+	}
+	return urls
+}
+
+// FindPhoto finds one of the restaurant's photos by its ID, or its url
+func (r *Restaurant) FindPhoto(idOrURL string) (int, bool) {
+	for i, p := range r.Photos {
+		if p.ID == idOrURL || p.URL == idOrURL {
+			return i, true
+		}
+	}
+	return -1, false
+}
+
+// ReorderPhotos puts the restaurant's photos in the order of ids, which must list every photo once
+func (r *Restaurant) ReorderPhotos(ids []string) error {
+	if len(ids) != len(r.Photos) {
+		return errors.New("sorry bro, the new order has to include every photo")
+	}
+
+	seen := map[string]bool{}
+	for order, id := range ids {
+		i, ok := r.FindPhoto(id)
+		if !ok || seen[id] {
+			return fmt.Errorf("sorry bro, photo %s is unknown or listed twice", id)
+		}
+		seen[id] = true
+		r.Photos[i].Order = order
+	}
+
+	r.Photos = r.SortedPhotos()
+	return nil
+}
+
+// SetCoverPhoto makes one of the restaurant's photos its cover
+func (r *Restaurant) SetCoverPhoto(id string) error {
+	r.Photos = r.SortedPhotos()
+	i, ok := r.FindPhoto(id)
+	if !ok {
+		return errors.New("sorry bro, unable to find that photo")
+	}
+
+	for j := range r.Photos {
+		r.Photos[j].Cover = j == i
+	}
+	return nil
+}
+
+// SaveRestaurantPhotos writes a restaurant's photos, in display order
+func SaveRestaurantPhotos(owner string, r Restaurant) error {
+	r.Photos = r.SortedPhotos()
+	return SaveRestaurantFields(owner, owner, "photos", r, []string{"photos"})
+}
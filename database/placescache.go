@@ -0,0 +1,83 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// PlacesCacheEntry is a cached Google Places response. Responses are stored as JSON
+// since the maps types only carry json tags. Mongo removes entries once they expire.
+type PlacesCacheEntry struct {
+	Key      string    `bson:"key"`
+	PlaceIDs []string  `bson:"placeId,omitempty"`
+	Data     string    `bson:"data"`
+	Expires  time.Time `bson:"expires"`
+}
+
+// GetPlacesCache decodes the cached response for key into out, reporting whether there was one
+func GetPlacesCache(key string, out interface{}) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// The TTL monitor only runs every minute, so expired entries are filtered out here as well
+	var entry PlacesCacheEntry
+	err := PlacesCollection.FindOne(ctx, bson.M{"key": key, "expires": bson.M{"$gt": time.Now()}}).Decode(&entry)
+	if err != nil {
+		return false
+	}
+
+	return json.Unmarshal([]byte(entry.Data), out) == nil
+}
+
+// SetPlacesCache caches a response under key for ttl. The place IDs it mentions
+// are stored alongside, so InvalidatePlacesCache can find it again.
+func SetPlacesCache(key string, placeIDs []string, v interface{}, ttl time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	entry := PlacesCacheEntry{
+		Key:      key,
+		PlaceIDs: placeIDs,
+		Data:     string(data),
+		Expires:  time.Now().Add(ttl),
+	}
+
+	_, err = PlacesCollection.ReplaceOne(ctx, bson.M{"key": key}, entry, options.Replace().SetUpsert(true))
+	return err
+}
+
+// InvalidatePlacesCache drops every cached response mentioning one of the given places
+func InvalidatePlacesCache(placeIDs ...string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	ids := []string{}
+	for _, id := range placeIDs {
+		if id != "" {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	_, err := PlacesCollection.DeleteMany(ctx, bson.M{"placeId": bson.M{"$in": ids}})
+	return err
+}
+
+// CountPlacesCache returns the number of live cache entries
+func CountPlacesCache() (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	return PlacesCollection.CountDocuments(ctx, bson.M{"expires": bson.M{"$gt": time.Now()}})
+}
@@ -0,0 +1,178 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// OpeningPeriod is a single stretch of time a restaurant is open during the week
+type OpeningPeriod struct {
+	Day   int    `bson:"day" json:"day"`     // 0 is Sunday, as in time.Weekday
+	Open  string `bson:"open" json:"open"`   // 24 hour "HH:MM"
+	Close string `bson:"close" json:"close"` // before Open when closing after midnight
+}
+
+// HoursException replaces a restaurant's weekly hours on a single date, such as a holiday
+type HoursException struct {
+	Date   string `bson:"date" json:"date"` // "YYYY-MM-DD"
+	Closed bool   `bson:"closed" json:"closed"`
+	Open   string `bson:"open" json:"open"`
+	Close  string `bson:"close" json:"close"`
+	Note   string `bson:"note" json:"note"`
+}
+
+// DietaryTags are the dietary tags a restaurant can choose from
+var DietaryTags = []string{
+	"vegetarian",
+	"vegan",
+	"gluten-free",
+	"dairy-free",
+	"nut-free",
+	"halal",
+	"kosher",
+}
+
+const (
+	maxStoryLength = 500
+	maxCuisines    = 10
+)
+
+// NormalizeProfile validates the richer profile fields of a restaurant, and tidies up its tags
+func (r *Restaurant) NormalizeProfile() error {
+	for _, p := range r.Hours {
+		if p.Day < 0 || p.Day > 6 {
+			return fmt.Errorf("sorry bro, %d is not a day of the week", p.Day)
+		}
+		if !validClock(p.Open) || !validClock(p.Close) {
+			return errors.New("sorry bro, opening hours have to look like 09:30")
+		}
+	}
+
+	for _, e := range r.Holidays {
+		if _, err := time.Parse("2006-01-02", e.Date); err != nil {
+			return errors.New("sorry bro, holiday dates have to look like 2020-12-25")
+		}
+		if !e.Closed && (!validClock(e.Open) || !validClock(e.Close)) {
+			return errors.New("sorry bro, holiday hours have to look like 09:30")
+		}
+	}
+
+	if r.TimeZone != "" {
+		if _, err := time.LoadLocation(r.TimeZone); err != nil {
+			return fmt.Errorf("sorry bro, unknown time zone %s", r.TimeZone)
+		}
+	}
+
+	r.Cuisines = normalizeTags(r.Cuisines)
+	if len(r.Cuisines) > maxCuisines {
+		return fmt.Errorf("sorry bro, at most %d cuisines please", maxCuisines)
+	}
+
+	r.Dietary = normalizeTags(r.Dietary)
+	for _, t := range r.Dietary {
+		if !stringInSlice(t, DietaryTags) {
+			return fmt.Errorf("sorry bro, unknown dietary tag %s", t)
+		}
+	}
+
+	if len([]rune(r.Story)) > maxStoryLength {
+		return fmt.Errorf("sorry bro, please keep your story under %d characters", maxStoryLength)
+	}
+
+	r.Instagram = strings.TrimPrefix(strings.TrimSpace(r.Instagram), "@")
+
+	if r.OrderingLink != "" {
+		u, err := url.Parse(r.OrderingLink)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return errors.New("sorry bro, the ordering link has to be a web address")
+		}
+	}
+
+	return nil
+}
+
+// IsOpenAt reports whether the restaurant is open at time t. The second result
+// is false when the restaurant hasn't told us its hours.
+func (r *Restaurant) IsOpenAt(t time.Time) (bool, bool) {
+	if len(r.Hours) == 0 && len(r.Holidays) == 0 {
+		return false, false
+	}
+
+	if r.TimeZone != "" {
+		if loc, err := time.LoadLocation(r.TimeZone); err == nil {
+			t = t.In(loc)
+		}
+	}
+	clock := t.Format("15:04")
+
+	// Holidays replace the weekly hours for the whole day
+	for _, e := range r.Holidays {
+		if e.Date == t.Format("2006-01-02") {
+			return !e.Closed && inPeriod(clock, e.Open, e.Close), true
+		}
+	}
+
+	yesterday := (int(t.Weekday()) + 6) % 7
+	for _, p := range r.Hours {
+		if p.Day == int(t.Weekday()) && inPeriod(clock, p.Open, p.Close) {
+			return true, true
+		}
+		// Periods which began yesterday and close after midnight
+		if p.Day == yesterday && p.Close < p.Open && clock < p.Close {
+			return true, true
+		}
+	}
+
+	return false, true
+}
+
+// HasTags reports whether the restaurant has every one of the given cuisine and dietary tags
+func (r *Restaurant) HasTags(cuisines, dietary []string) bool {
+	for _, c := range normalizeTags(cuisines) {
+		if !stringInSlice(c, r.Cuisines) {
+			return false
+		}
+	}
+	for _, d := range normalizeTags(dietary) {
+		if !stringInSlice(d, r.Dietary) {
+			return false
+		}
+	}
+	return true
+}
+
+// inPeriod checks whether a "HH:MM" clock falls within the part of a period on its opening day
+func inPeriod(clock, open, close string) bool {
+	if close <= open {
+		return clock >= open
+	}
+	return clock >= open && clock < close
+}
+
+func validClock(s string) bool {
+	_, err := time.Parse("15:04", s)
+	return err == nil && len(s) == 5
+}
+
+func normalizeTags(tags []string) []string {
+	out := []string{}
+	for _, t := range tags {
+		t = strings.ToLower(strings.TrimSpace(t))
+		if t != "" && !stringInSlice(t, out) {
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+func stringInSlice(a string, list []string) bool {
+	for _, b := range list {
+		if b == a {
+			return true
+		}
+	}
+	return false
+}
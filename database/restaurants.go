@@ -3,6 +3,7 @@ package database
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"time"
 
 	"github.com/rishabh-bector/BenevolentBitesBack/auth"
@@ -26,8 +27,17 @@ type Restaurant struct {
 	Employees    []map[string]string `bson:"employees" json:"employees"`
 	Verified     bool                `bson:"verified" json:"verified"`
 	Published    bool                `bson:"published" json:"published"`
+	Paused       bool                `bson:"paused" json:"paused"`
 	Signed       bool                `bson:"signed" json:"signed"`
-	Photos       []string            `bson:"photos" json:"photos"`
+	Photos       []Photo             `bson:"photos" json:"photos"`
+	Hours        []OpeningPeriod     `bson:"hours" json:"hours"`
+	Holidays     []HoursException    `bson:"holidays" json:"holidays"`
+	TimeZone     string              `bson:"timezone" json:"timezone"`
+	Cuisines     []string            `bson:"cuisines" json:"cuisines"`
+	Dietary      []string            `bson:"dietary" json:"dietary"`
+	Instagram    string              `bson:"instagram" json:"instagram"`
+	OrderingLink string              `bson:"ordering" json:"ordering"`
+	Story        string              `bson:"story" json:"story"`
 
 	// Constant
 	Owner    string          `bson:"owner" json:"owner"`
@@ -35,6 +45,23 @@ type Restaurant struct {
 	PlaceID  string          `bson:"placeId" json:"placeId"`
 	PassHash string          `bson:"passHash" json:"passHash"`
 	Square   auth.SquareAuth `bson:"square" json:"square"`
+	Location *GeoPoint       `bson:"location,omitempty" json:"location,omitempty"` // From PlaceID, see SetRestaurantLocation
+	NameKey  string          `bson:"nameKey" json:"-"`                             // Lowercased name, see AutocompleteRestaurants
+
+	// Campaigns and news for followers, see AddRestaurantCampaign and PostRestaurantUpdate
+	Campaigns []Campaign         `bson:"campaigns" json:"campaigns"`
+	Updates   []RestaurantUpdate `bson:"updates" json:"updates"`
+
+	// Public page, see ChangeRestaurantSlug
+	Slug          string   `bson:"slug" json:"slug"`
+	PreviousSlugs []string `bson:"previousSlugs" json:"previousSlugs"`
+
+	// Admin only
+	Splits         []SplitPolicy `bson:"splits" json:"splits"`
+	MaxOutstanding int           `bson:"maxOutstanding" json:"maxOutstanding"`
+	MaxCardBalance int           `bson:"maxCardBalance" json:"maxCardBalance"`
+	Closing        bool          `bson:"closing" json:"closing"`
+	Archived       bool          `bson:"archived" json:"archived"`
 }
 
 // UpdateRestaurant adds a new restaurant into the DB if it doesn't yet exist
@@ -49,6 +76,7 @@ func UpdateRestaurant(owner string, r Restaurant) error {
 		// Marshal data for Mongo
 		r.Owner = owner
 		r.UUID = auth.GenerateUUID()
+		r.NameKey = nameKey(r.Name)
 		marshaled, err := bson.Marshal(r)
 		if err != nil {
 			log.Error(err)
@@ -56,24 +84,81 @@ func UpdateRestaurant(owner string, r Restaurant) error {
 		}
 
 		_, err = RestCollection.InsertOne(ctx, marshaled)
+		if isDuplicateKeyOn(err, placeIDIndex) {
+			return ErrPlaceTaken
+		}
 		if err != nil {
 			return err
 		}
 
+		if _, err := AssignRestaurantSlug(r); err != nil {
+			log.Error("BB: unable to assign slug: ", err)
+		}
+
+		recordRestaurantVersion(bson.M{"owner": owner}, owner, "create")
+
 		return nil
 	}
 
 	// Update existing restaurant
 	merged := MergeRestaurants(oldR, r)
+	merged.NameKey = nameKey(merged.Name)
 	filter := bson.D{{"owner", owner}}
 	update := bson.D{{"$set", merged}}
-	RestCollection.UpdateOne(ctx, filter, update)
+	_, err := RestCollection.UpdateOne(ctx, filter, update)
+	if isDuplicateKeyOn(err, placeIDIndex) {
+		return ErrPlaceTaken
+	}
+	if err != nil {
+		return err
+	}
+
+	recordRestaurantVersion(bson.M{"owner": owner}, owner, "update")
 
 	return nil
 }
 
 var NilRestaurant = Restaurant{Owner: "nil"}
 
+// ErrPlaceTaken is returned when a restaurant is saved with a place ID another restaurant already has
+var ErrPlaceTaken = errors.New("sorry bro, another restaurant has already claimed that address")
+
+// placeIDIndex is the name of the unique index on placeId, the default Mongo gives it
+const placeIDIndex = "placeId_1"
+
+// DuplicatePlace is a place claimed by more than one restaurant, from before place IDs were unique
+type DuplicatePlace struct {
+	PlaceID string   `bson:"_id" json:"placeId"`
+	Owners  []string `bson:"owners" json:"owners"`
+	UUIDs   []string `bson:"uuids" json:"uuids"`
+}
+
+// FindDuplicatePlaceIDs lists every place claimed by more than one restaurant
+func FindDuplicatePlaceIDs() ([]DuplicatePlace, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	pipeline := []bson.M{
+		{"$match": bson.M{"placeId": bson.M{"$type": "string", "$gt": ""}}},
+		{"$group": bson.M{
+			"_id":    "$placeId",
+			"owners": bson.M{"$push": "$owner"},
+			"uuids":  bson.M{"$push": "$uuid"},
+			"count":  bson.M{"$sum": 1},
+		}},
+		{"$match": bson.M{"count": bson.M{"$gt": 1}}},
+	}
+
+	cur, err := RestCollection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+
+	dups := []DuplicatePlace{}
+	err = cur.All(ctx, &dups)
+	return dups, err
+}
+
 // DoesRestaurantExist searches Mongo for a restaurant
 func DoesRestaurantExist(email string) Restaurant {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
@@ -107,6 +192,33 @@ func DoesRestaurantExistUUID(uuid string) Restaurant {
 	return result
 }
 
+// GetRestaurantsByPlaceIDs finds the restaurants with any of the given place IDs in one query, keyed by place ID
+func GetRestaurantsByPlaceIDs(placeIDs []string) (map[string]Restaurant, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	found := map[string]Restaurant{}
+	if len(placeIDs) == 0 {
+		return found, nil
+	}
+
+	cur, err := RestCollection.Find(ctx, bson.M{"placeId": bson.M{"$in": placeIDs}})
+	if err != nil {
+		return nil, err
+	}
+
+	var rests []Restaurant
+	if err := cur.All(ctx, &rests); err != nil {
+		return nil, err
+	}
+
+	for _, r := range rests {
+		found[r.PlaceID] = r
+	}
+
+	return found, nil
+}
+
 // DoesRestaurantExistUUID searches Mongo for a restaurant, by Place ID
 func DoesRestaurantExistPlaceID(placeID string) Restaurant {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
@@ -133,6 +245,8 @@ func UpdateRestaurantSquareAuth(owner string, s auth.SquareAuth) error {
 	update := bson.D{{"$set", bson.D{{"square", s}}}}
 	RestCollection.UpdateOne(ctx, filter, update)
 
+	recordRestaurantVersion(bson.M{"owner": owner}, owner, "square")
+
 	return nil
 }
 
@@ -181,13 +295,16 @@ func MergeRestaurants(uOld, uNew Restaurant) Restaurant {
 			if k == "photos" {
 				if vc, ok := v.([]interface{}); ok {
 					if len(vc) > 0 {
-						mOut[k] = []string{}
-						for _, v2 := range vc {
-							mOut[k] = append(mOut[k].([]string), v2.(string))
-						}
+						mOut[k] = vc
 					}
 				}
 			}
+			// Profile lists can be emptied, so only a missing (null) list is left alone
+			if k == "hours" || k == "holidays" || k == "cuisines" || k == "dietary" {
+				if vc, ok := v.([]interface{}); ok {
+					mOut[k] = vc
+				}
+			}
 		}
 	}
 
@@ -0,0 +1,99 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// benchPage is the size of a page of Google search results
+const benchPage = 20
+
+// withBenchRestaurants fills a scratch database with a page worth of partners, and returns
+// their place IDs and a func to drop it again. It needs a Mongo at M_URL.
+func withBenchRestaurants(b *testing.B) ([]string, func()) {
+	cleanup := withTestDB(b)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	ids := []string{}
+	docs := []interface{}{}
+	for i := 0; i < benchPage; i++ {
+		id := fmt.Sprintf("bench-place-%d", i)
+		ids = append(ids, id)
+		docs = append(docs, Restaurant{Owner: fmt.Sprintf("owner%d@example.com", i), UUID: id, PlaceID: id})
+	}
+	if _, err := RestCollection.InsertMany(ctx, docs); err != nil {
+		cleanup()
+		b.Fatal(err)
+	}
+
+	return ids, cleanup
+}
+
+// BenchmarkPartnerLookupPerResult looks up each search result on its own, like searchResults used to
+func BenchmarkPartnerLookupPerResult(b *testing.B) {
+	ids, cleanup := withBenchRestaurants(b)
+	defer cleanup()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		for _, id := range ids {
+			if r := DoesRestaurantExistPlaceID(id); r.Owner == "nil" {
+				b.Fatal("missing restaurant ", id)
+			}
+		}
+	}
+}
+
+// BenchmarkPartnerLookupBatch looks up a whole page of search results at once
+func BenchmarkPartnerLookupBatch(b *testing.B) {
+	ids, cleanup := withBenchRestaurants(b)
+	defer cleanup()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		found, err := GetRestaurantsByPlaceIDs(ids)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if len(found) != len(ids) {
+			b.Fatal("found ", len(found), " of ", len(ids), " restaurants")
+		}
+	}
+}
+
+func TestMergeRestaurantsProfile(t *testing.T) {
+	old := Restaurant{Name: "Taqueria El Sol", Cuisines: []string{"tacos"}, Dietary: []string{"vegan"}}
+	edits := Restaurant{
+		Hours:    []OpeningPeriod{{Day: 1, Open: "09:00", Close: "17:00"}},
+		Holidays: []HoursException{{Date: "2020-12-25", Closed: true}},
+		Cuisines: []string{"mexican", "tacos"},
+		Dietary:  []string{},
+	}
+
+	merged := MergeRestaurants(old, edits)
+	if merged.Name != "Taqueria El Sol" {
+		t.Errorf("expected the name to be kept, got %q", merged.Name)
+	}
+	if len(merged.Hours) != 1 || merged.Hours[0] != edits.Hours[0] {
+		t.Errorf("expected the new hours, got %+v", merged.Hours)
+	}
+	if len(merged.Holidays) != 1 || merged.Holidays[0] != edits.Holidays[0] {
+		t.Errorf("expected the new holidays, got %+v", merged.Holidays)
+	}
+	if len(merged.Cuisines) != 2 || merged.Cuisines[0] != "mexican" {
+		t.Errorf("expected the new cuisines, got %v", merged.Cuisines)
+	}
+	if len(merged.Dietary) != 0 {
+		t.Errorf("expected the dietary tags to be cleared, got %v", merged.Dietary)
+	}
+
+	// Leaving the lists out keeps them
+	merged = MergeRestaurants(merged, Restaurant{Name: "El Sol"})
+	if len(merged.Hours) != 1 || len(merged.Cuisines) != 2 {
+		t.Errorf("expected the lists to be kept, got %+v", merged)
+	}
+}
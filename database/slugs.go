@@ -0,0 +1,199 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const maxSlugLength = 60
+
+var (
+	slugAccents = strings.NewReplacer(
+		"à", "a", "á", "a", "â", "a", "ä", "a", "ã", "a", "å", "a",
+		"è", "e", "é", "e", "ê", "e", "ë", "e",
+		"ì", "i", "í", "i", "î", "i", "ï", "i",
+		"ò", "o", "ó", "o", "ô", "o", "ö", "o", "õ", "o",
+		"ù", "u", "ú", "u", "û", "u", "ü", "u",
+		"ñ", "n", "ç", "c", "&", " and ", "'", "", "’", "",
+	)
+	slugInvalid = regexp.MustCompile(`[^a-z0-9]+`)
+	slugValid   = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+)
+
+// Slugify turns a restaurant name into a url friendly slug, such as "joes-pizza"
+func Slugify(s string) string {
+	s = slugAccents.Replace(strings.ToLower(s))
+	s = strings.Trim(slugInvalid.ReplaceAllString(s, "-"), "-")
+	if len(s) > maxSlugLength {
+		s = strings.TrimRight(s[:maxSlugLength], "-")
+	}
+	return s
+}
+
+// DoesRestaurantExistSlug searches Mongo for a restaurant by its current slug. If the
+// slug used to belong to a restaurant, that restaurant is returned with moved set.
+func DoesRestaurantExistSlug(slug string) (r Restaurant, moved bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err := RestCollection.FindOne(ctx, bson.M{"slug": slug}).Decode(&r)
+	if err == nil {
+		return r, false
+	}
+
+	err = RestCollection.FindOne(ctx, bson.M{"previousSlugs": slug}).Decode(&r)
+	if err == nil {
+		return r, true
+	}
+
+	return NilRestaurant, false
+}
+
+// isSlugTaken checks whether a slug belongs, or used to belong, to another restaurant
+func isSlugTaken(slug, restUUID string) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	filter := bson.M{
+		"uuid": bson.M{"$ne": restUUID},
+		"$or":  []bson.M{{"slug": slug}, {"previousSlugs": slug}},
+	}
+	n, err := RestCollection.CountDocuments(ctx, filter)
+	return n > 0, err
+}
+
+// uniqueSlug finds a free slug for a restaurant, based on its name and then its city
+func uniqueSlug(r Restaurant) (string, error) {
+	base := Slugify(r.Name)
+	if base == "" {
+		base = "restaurant"
+	}
+
+	candidates := []string{base}
+	if city := Slugify(r.City); city != "" {
+		candidates = append(candidates, fmt.Sprintf("%s-%s", base, city))
+	}
+	for i := 2; i < 100; i++ {
+		candidates = append(candidates, fmt.Sprintf("%s-%d", base, i))
+	}
+
+	for _, slug := range candidates {
+		taken, err := isSlugTaken(slug, r.UUID)
+		if err != nil {
+			return "", err
+		}
+		if !taken {
+			return slug, nil
+		}
+	}
+
+	return fmt.Sprintf("%s-%s", base, r.UUID[:8]), nil
+}
+
+// AssignRestaurantSlug gives a restaurant its first slug, if it doesn't have one yet
+func AssignRestaurantSlug(r Restaurant) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if r.Slug != "" {
+		return r.Slug, nil
+	}
+
+	slug, err := uniqueSlug(r)
+	if err != nil {
+		return "", err
+	}
+
+	filter := bson.M{"uuid": r.UUID, "slug": bson.M{"$in": []interface{}{"", nil}}}
+	_, err = RestCollection.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"slug": slug}})
+	if err != nil {
+		return "", err
+	}
+
+	return slug, nil
+}
+
+// ChangeRestaurantSlug gives a restaurant a new slug. The old slug keeps
+// working, and redirects to the new one.
+func ChangeRestaurantSlug(owner, slug string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if !slugValid.MatchString(slug) || len(slug) > maxSlugLength {
+		return errors.New("sorry bro, slugs can only have lowercase letters, numbers and dashes")
+	}
+
+	r := DoesRestaurantExist(owner)
+	if r.Owner == "nil" {
+		return errors.New("sorry bro, that restaurant doesn't exist")
+	}
+	if r.Slug == slug {
+		return nil
+	}
+
+	taken, err := isSlugTaken(slug, r.UUID)
+	if err != nil {
+		return err
+	}
+	if taken {
+		return errors.New("sorry bro, that slug is already taken")
+	}
+
+	filter := bson.M{"owner": owner}
+	update := bson.M{
+		"$set":  bson.M{"slug": slug},
+		"$pull": bson.M{"previousSlugs": slug},
+	}
+	_, err = RestCollection.UpdateOne(ctx, filter, update)
+	if isDuplicateKeyError(err) {
+		return errors.New("sorry bro, that slug is already taken")
+	}
+	if err != nil {
+		return err
+	}
+
+	// Mongo won't $pull and $addToSet the same field at once, so the old slug goes in afterwards
+	if r.Slug != "" {
+		update = bson.M{"$addToSet": bson.M{"previousSlugs": r.Slug}}
+		_, err = RestCollection.UpdateOne(ctx, filter, update)
+		if err != nil {
+			return err
+		}
+	}
+
+	recordRestaurantVersion(filter, owner, "slug")
+
+	return nil
+}
+
+// AssignMissingSlugs backfills slugs for restaurants created before slugs existed
+func AssignMissingSlugs() {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	cur, err := RestCollection.Find(ctx, bson.M{"slug": bson.M{"$in": []interface{}{"", nil}}})
+	if err != nil {
+		log.Error(err)
+		return
+	}
+
+	var rests []Restaurant
+	if err := cur.All(ctx, &rests); err != nil {
+		log.Error(err)
+		return
+	}
+
+	for _, r := range rests {
+		if _, err := AssignRestaurantSlug(r); err != nil {
+			log.Error("BB: unable to assign slug: ", err)
+		}
+	}
+}
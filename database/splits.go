@@ -0,0 +1,82 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"math"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// SplitPolicy describes how the credit sold by a restaurant is divided between
+// its employees, the restaurant itself, and the Benevolent Bites platform.
+// Shares are fractions of a sale and must add up to 1.
+type SplitPolicy struct {
+	Employees  float64   `bson:"employees" json:"employees"`
+	Restaurant float64   `bson:"restaurant" json:"restaurant"`
+	Platform   float64   `bson:"platform" json:"platform"`
+	Effective  time.Time `bson:"effective" json:"effective"`
+	SetBy      string    `bson:"setBy" json:"setBy"`
+}
+
+// DefaultSplit is applied to any sale made before a restaurant had a policy
+var DefaultSplit = SplitPolicy{Employees: 0.25, Restaurant: 0.75, Platform: 0}
+
+// Validate makes sure that every share is sensible and that nothing is lost or invented
+func (p SplitPolicy) Validate() error {
+	if p.Employees < 0 || p.Restaurant < 0 || p.Platform < 0 {
+		return errors.New("sorry bro, split shares cannot be negative")
+	}
+	if math.Abs(p.Employees+p.Restaurant+p.Platform-1) > 0.0001 {
+		return errors.New("sorry bro, split shares must add up to 1")
+	}
+	return nil
+}
+
+// Apply divides an amount of credit according to the policy.
+// Any rounding remainder goes to the restaurant.
+func (p SplitPolicy) Apply(amount int) (employees, restaurant, platform int) {
+	employees = int(float64(amount) * p.Employees)
+	platform = int(float64(amount) * p.Platform)
+	restaurant = amount - employees - platform
+	return
+}
+
+// SplitAt returns the policy which was in effect for the restaurant at time t
+func (r *Restaurant) SplitAt(t time.Time) SplitPolicy {
+	policy := DefaultSplit
+	var effective time.Time
+	for _, s := range r.Splits {
+		if !s.Effective.After(t) && !s.Effective.Before(effective) {
+			policy = s
+			effective = s.Effective
+		}
+	}
+	return policy
+}
+
+// AddRestaurantSplit records a new split policy for a restaurant.
+// Older policies are kept so that past sales are still reported correctly.
+func AddRestaurantSplit(uuid string, p SplitPolicy) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := p.Validate(); err != nil {
+		return err
+	}
+
+	filter := bson.M{"uuid": uuid}
+	update := bson.M{"$push": bson.M{"splits": p}}
+	res, err := RestCollection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return errors.New("sorry bro, that restaurant doesn't exist")
+	}
+
+	recordRestaurantVersion(filter, p.SetBy, "split")
+
+	return nil
+}
@@ -0,0 +1,62 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/rishabh-bector/BenevolentBitesBack/auth"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// MaxRestaurantUpdates is how many of its latest updates a restaurant keeps
+const MaxRestaurantUpdates = 50
+
+// RestaurantUpdate is news a restaurant shares with its followers
+type RestaurantUpdate struct {
+	ID     string    `bson:"id" json:"id"`
+	Title  string    `bson:"title" json:"title"`
+	Body   string    `bson:"body" json:"body"`
+	Posted time.Time `bson:"posted" json:"posted"`
+}
+
+// LatestUpdates returns up to n of a restaurant's updates, newest first
+func (r *Restaurant) LatestUpdates(n int) []RestaurantUpdate {
+	out := []RestaurantUpdate{}
+	for i := len(r.Updates) - 1; i >= 0 && len(out) < n; i-- {
+		out = append(out, r.Updates[i])
+	}
+	return out
+}
+
+// PostRestaurantUpdate adds an update to a restaurant, dropping its oldest ones past MaxRestaurantUpdates
+func PostRestaurantUpdate(owner string, u RestaurantUpdate) (RestaurantUpdate, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	u.Title = strings.TrimSpace(u.Title)
+	u.Body = strings.TrimSpace(u.Body)
+	if u.Title == "" || u.Body == "" {
+		return u, errors.New("sorry bro, an update needs a title and some text")
+	}
+	if len(u.Title) > 200 || len(u.Body) > 5000 {
+		return u, errors.New("sorry bro, that update is too long")
+	}
+
+	u.ID = auth.GenerateUUID()
+	u.Posted = time.Now()
+
+	filter := bson.M{"owner": owner}
+	update := bson.M{"$push": bson.M{"updates": bson.M{"$each": []RestaurantUpdate{u}, "$slice": -MaxRestaurantUpdates}}}
+	res, err := RestCollection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return u, err
+	}
+	if res.MatchedCount == 0 {
+		return u, errors.New("sorry bro, that restaurant doesn't exist")
+	}
+
+	return u, nil
+}
@@ -1,7 +1,15 @@
 package database
 
 import (
+	"context"
+	"errors"
+	"time"
+
 	"github.com/rishabh-bector/BenevolentBitesBack/auth"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 // ValidateUser authorizes incoming frontend requests through the user's JWT
@@ -13,3 +21,102 @@ func ValidateUser(token string) string {
 	}
 	return claims["email"].(string)
 }
+
+// User is someone buying credit. They're created the first time they save a restaurant.
+type User struct {
+	Email     string    `bson:"email" json:"email"`
+	Favorites []string  `bson:"favorites" json:"favorites"` // Restaurant UUIDs, shown as favorites in search
+	Following []string  `bson:"following" json:"following"` // Restaurant UUIDs, emailed about campaigns and updates
+	Created   time.Time `bson:"created" json:"created"`
+}
+
+// GetUser returns a user, or an empty one if they haven't saved anything yet
+func GetUser(email string) (User, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	u := User{Email: email, Favorites: []string{}, Following: []string{}}
+	err := UserCollection.FindOne(ctx, bson.M{"email": email}).Decode(&u)
+	if err == mongo.ErrNoDocuments {
+		return u, nil
+	}
+
+	return u, err
+}
+
+// SetFavorite adds a restaurant to a user's favorites, or removes it
+func SetFavorite(email, restUUID string, favorite bool) error {
+	return setUserRestaurant(email, "favorites", restUUID, favorite)
+}
+
+// SetFollowing makes a user follow a restaurant, or stop following it
+func SetFollowing(email, restUUID string, follow bool) error {
+	return setUserRestaurant(email, "following", restUUID, follow)
+}
+
+func setUserRestaurant(email, list, restUUID string, add bool) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if add {
+		if r := DoesRestaurantExistUUID(restUUID); r.Owner == "nil" {
+			return errors.New("sorry bro, that restaurant doesn't exist")
+		}
+	}
+
+	update := bson.M{
+		"$setOnInsert": bson.M{"created": time.Now()},
+	}
+	if add {
+		update["$addToSet"] = bson.M{list: restUUID}
+	} else {
+		update["$pull"] = bson.M{list: restUUID}
+	}
+
+	_, err := UserCollection.UpdateOne(ctx, bson.M{"email": email}, update, options.Update().SetUpsert(true))
+	return err
+}
+
+// GetFollowers returns the emails of everyone following a restaurant
+func GetFollowers(restUUID string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	cur, err := UserCollection.Find(ctx, bson.M{"following": restUUID}, options.Find().SetProjection(bson.M{"email": 1}))
+	if err != nil {
+		return nil, err
+	}
+
+	var users []User
+	if err := cur.All(ctx, &users); err != nil {
+		return nil, err
+	}
+
+	emails := []string{}
+	for _, u := range users {
+		emails = append(emails, u.Email)
+	}
+
+	return emails, nil
+}
+
+// GetRestaurantsByUUIDs finds every restaurant with one of the given UUIDs
+func GetRestaurantsByUUIDs(uuids []string) ([]Restaurant, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	rests := []Restaurant{}
+	if len(uuids) == 0 {
+		return rests, nil
+	}
+
+	cur, err := RestCollection.Find(ctx, bson.M{"uuid": bson.M{"$in": uuids}})
+	if err != nil {
+		return nil, err
+	}
+	if err := cur.All(ctx, &rests); err != nil {
+		return nil, err
+	}
+
+	return rests, nil
+}
@@ -0,0 +1,203 @@
+package database
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// VerificationTTL is how long a phone verification code can be used for
+	VerificationTTL = 10 * time.Minute
+
+	// MaxVerificationAttempts is how many codes can be tried before a new one has to be sent
+	MaxVerificationAttempts = 5
+)
+
+var (
+	ErrNoVerification      = errors.New("sorry bro, no code was sent, please ask for a new one")
+	ErrVerificationExpired = errors.New("sorry bro, that code has expired, please ask for a new one")
+	ErrTooManyAttempts     = errors.New("sorry bro, too many wrong codes, please ask for a new one")
+	ErrWrongCode           = errors.New("sorry bro, wrong code")
+)
+
+// Twilio call and message statuses which mean the code never arrived
+var failedDeliveryStatuses = []string{"busy", "no-answer", "failed", "canceled", "undelivered"}
+
+// Verification is a phone verification code sent to a restaurant. Each owner has at most
+// one, and sending a new code replaces it. Only a hash of the code is stored.
+// Mongo removes sessions once they expire.
+type Verification struct {
+	Email    string    `bson:"email" json:"-"`
+	Phone    string    `bson:"phone" json:"phone"`
+	CodeHash string    `bson:"codeHash" json:"-"`
+	Attempts int       `bson:"attempts" json:"attempts"`
+	Used     bool      `bson:"used" json:"used"`
+	Created  time.Time `bson:"created" json:"created"`
+	Expires  time.Time `bson:"expires" json:"expires"`
+
+	// How the code was sent, and what Twilio last reported about it
+	Method string `bson:"method" json:"method"`
+	Sid    string `bson:"sid" json:"-"`
+	Status string `bson:"status" json:"status"`
+}
+
+// Failed reports whether Twilio couldn't get the code through, like when nobody answered the call
+func (v *Verification) Failed() bool {
+	for _, s := range failedDeliveryStatuses {
+		if v.Status == s {
+			return true
+		}
+	}
+	return false
+}
+
+// NewVerification creates the session for a code sent at time now.
+// sid and status are Twilio's ID for the call or message, and its status when it was sent.
+func NewVerification(email, phone, code, method, sid, status string, now time.Time) Verification {
+	return Verification{
+		Email:    email,
+		Phone:    phone,
+		CodeHash: hashCode(email, code),
+		Created:  now,
+		Expires:  now.Add(VerificationTTL),
+		Method:   method,
+		Sid:      sid,
+		Status:   status,
+	}
+}
+
+// Usable returns why the session can't take another attempt at time now, or nil if it can.
+// CheckVerification's filter is the same rule, for Mongo.
+func (v *Verification) Usable(now time.Time) error {
+	switch {
+	case v.Used:
+		return ErrNoVerification
+	case !v.Expires.After(now):
+		return ErrVerificationExpired
+	case v.Attempts >= MaxVerificationAttempts:
+		return ErrTooManyAttempts
+	}
+	return nil
+}
+
+// Check is CheckVerification for a session held in memory, such as in tests
+func (v *Verification) Check(code string, now time.Time) error {
+	if err := v.Usable(now); err != nil {
+		return err
+	}
+
+	v.Attempts++
+	if !v.matches(code) {
+		return ErrWrongCode
+	}
+
+	v.Used = true
+	return nil
+}
+
+func (v *Verification) matches(code string) bool {
+	return subtle.ConstantTimeCompare([]byte(v.CodeHash), []byte(hashCode(v.Email, code))) == 1
+}
+
+// StartVerification stores a newly sent code for a restaurant owner, replacing any earlier one
+func StartVerification(email, phone, code, method, sid, status string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	v := NewVerification(email, phone, code, method, sid, status, time.Now())
+
+	_, err := VerifyCollection.ReplaceOne(ctx, bson.M{"email": email}, v, options.Replace().SetUpsert(true))
+	return err
+}
+
+// GetVerification returns the latest verification session of a restaurant owner
+func GetVerification(email string) (Verification, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var v Verification
+	err := VerifyCollection.FindOne(ctx, bson.M{"email": email}).Decode(&v)
+	if err == mongo.ErrNoDocuments {
+		return v, ErrNoVerification
+	}
+
+	return v, err
+}
+
+// SetVerificationStatus records the status Twilio reported for a call or message
+func SetVerificationStatus(sid, status string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := VerifyCollection.UpdateOne(ctx, bson.M{"sid": sid}, bson.M{"$set": bson.M{"status": status}})
+	return err
+}
+
+// CheckVerification checks a code against the owner's session. Every try counts as an attempt,
+// and a correct code can only be used once.
+func CheckVerification(email, code string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// Count the attempt first, so parallel guesses can't get past the limit
+	filter := bson.M{
+		"email":    email,
+		"used":     false,
+		"expires":  bson.M{"$gt": time.Now()},
+		"attempts": bson.M{"$lt": MaxVerificationAttempts},
+	}
+	update := bson.M{"$inc": bson.M{"attempts": 1}}
+
+	var v Verification
+	err := VerifyCollection.FindOneAndUpdate(ctx, filter, update, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&v)
+	if err == mongo.ErrNoDocuments {
+		return verificationError(ctx, email)
+	}
+	if err != nil {
+		return err
+	}
+
+	if !v.matches(code) {
+		return ErrWrongCode
+	}
+
+	res, err := VerifyCollection.UpdateOne(ctx, bson.M{"email": email, "codeHash": v.CodeHash, "used": false}, bson.M{"$set": bson.M{"used": true}})
+	if err != nil {
+		return err
+	}
+	if res.ModifiedCount == 0 {
+		return ErrNoVerification
+	}
+
+	return nil
+}
+
+// verificationError works out why an owner's session can't take another attempt
+func verificationError(ctx context.Context, email string) error {
+	var v Verification
+	err := VerifyCollection.FindOne(ctx, bson.M{"email": email}).Decode(&v)
+	if err == mongo.ErrNoDocuments {
+		return ErrNoVerification
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := v.Usable(time.Now()); err != nil {
+		return err
+	}
+	return ErrTooManyAttempts
+}
+
+func hashCode(email, code string) string {
+	sum := sha256.Sum256([]byte(email + ":" + code))
+	return hex.EncodeToString(sum[:])
+}
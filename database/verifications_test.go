@@ -0,0 +1,140 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const testVerifyEmail = "owner@example.com"
+
+// verifyStore is a place verification sessions are kept, so the same rules can be checked
+// against Mongo and against a session held in memory
+type verifyStore interface {
+	start(code string)
+	check(code string) error
+	expire()
+}
+
+type memVerifyStore struct {
+	v *Verification
+}
+
+func (m *memVerifyStore) start(code string) {
+	v := NewVerification(testVerifyEmail, "+15555550100", code, "sms", "SM1", "queued", time.Now())
+	m.v = &v
+}
+
+func (m *memVerifyStore) check(code string) error {
+	if m.v == nil {
+		return ErrNoVerification
+	}
+	return m.v.Check(code, time.Now())
+}
+
+func (m *memVerifyStore) expire() {
+	m.v.Expires = time.Now().Add(-time.Second)
+}
+
+type mongoVerifyStore struct {
+	t *testing.T
+}
+
+func (m mongoVerifyStore) start(code string) {
+	if err := StartVerification(testVerifyEmail, "+15555550100", code, "sms", "SM1", "queued"); err != nil {
+		m.t.Fatal(err)
+	}
+}
+
+func (m mongoVerifyStore) check(code string) error {
+	return CheckVerification(testVerifyEmail, code)
+}
+
+func (m mongoVerifyStore) expire() {
+	update := bson.M{"$set": bson.M{"expires": time.Now().Add(-time.Second)}}
+	if _, err := VerifyCollection.UpdateOne(context.Background(), bson.M{"email": testVerifyEmail}, update); err != nil {
+		m.t.Fatal(err)
+	}
+}
+
+// verifyScenarios are the rules every verifyStore has to follow
+var verifyScenarios = []struct {
+	name string
+	run  func(t *testing.T, s verifyStore)
+}{
+	{"no code sent", func(t *testing.T, s verifyStore) {
+		if err := s.check("1234"); err != ErrNoVerification {
+			t.Errorf("expected ErrNoVerification, got %v", err)
+		}
+	}},
+	{"right code", func(t *testing.T, s verifyStore) {
+		s.start("1234")
+		if err := s.check("4321"); err != ErrWrongCode {
+			t.Errorf("expected ErrWrongCode, got %v", err)
+		}
+		if err := s.check("1234"); err != nil {
+			t.Errorf("expected the code to work, got %v", err)
+		}
+	}},
+	{"single use", func(t *testing.T, s verifyStore) {
+		s.start("1234")
+		if err := s.check("1234"); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.check("1234"); err != ErrNoVerification {
+			t.Errorf("expected a used code to fail with ErrNoVerification, got %v", err)
+		}
+	}},
+	{"expiry", func(t *testing.T, s verifyStore) {
+		s.start("1234")
+		s.expire()
+		if err := s.check("1234"); err != ErrVerificationExpired {
+			t.Errorf("expected ErrVerificationExpired, got %v", err)
+		}
+	}},
+	{"attempt limit", func(t *testing.T, s verifyStore) {
+		s.start("1234")
+		for i := 0; i < MaxVerificationAttempts; i++ {
+			if err := s.check("0000"); err != ErrWrongCode {
+				t.Fatalf("attempt %d: expected ErrWrongCode, got %v", i+1, err)
+			}
+		}
+		if err := s.check("1234"); err != ErrTooManyAttempts {
+			t.Errorf("expected ErrTooManyAttempts, even for the right code, got %v", err)
+		}
+	}},
+	{"new code resets", func(t *testing.T, s verifyStore) {
+		s.start("1234")
+		for i := 0; i < MaxVerificationAttempts; i++ {
+			s.check("0000")
+		}
+		s.start("5678")
+		if err := s.check("1234"); err != ErrWrongCode {
+			t.Errorf("expected the old code to be replaced, got %v", err)
+		}
+		if err := s.check("5678"); err != nil {
+			t.Errorf("expected the new code to work, got %v", err)
+		}
+	}},
+}
+
+func TestVerificationRules(t *testing.T) {
+	for _, sc := range verifyScenarios {
+		t.Run(sc.name, func(t *testing.T) {
+			sc.run(t, &memVerifyStore{})
+		})
+	}
+}
+
+func TestMongoVerificationRules(t *testing.T) {
+	defer withTestDB(t)()
+
+	for _, sc := range verifyScenarios {
+		t.Run(sc.name, func(t *testing.T) {
+			VerifyCollection.DeleteMany(context.Background(), bson.M{})
+			sc.run(t, mongoVerifyStore{t})
+		})
+	}
+}
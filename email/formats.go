@@ -11,3 +11,61 @@ var ReportFormat = `
 			The Benevolent Bites Team
 
 `
+
+var NominationFormat = `
+
+	Hi,
+
+		Good news! %s, which you nominated, has just signed up for Benevolent Bites. As soon as their page goes live, you'll be able to buy credit there at %s.
+
+		Thanks for supporting your local restaurants,
+		
+			The Benevolent Bites Team
+
+`
+
+var CampaignFormat = `
+
+	Hi,
+
+		%s, which you follow on Benevolent Bites, has started a campaign: %s
+
+		%s
+
+		You can support them at %s.
+
+		Thanks for supporting your local restaurants,
+		
+			The Benevolent Bites Team
+
+`
+
+var UpdateFormat = `
+
+	Hi,
+
+		%s, which you follow on Benevolent Bites, has posted an update: %s
+
+		%s
+
+		See more at %s.
+
+		Thanks for supporting your local restaurants,
+		
+			The Benevolent Bites Team
+
+`
+
+var ClosureFormat = `
+
+	Hi,
+
+		%s is closing its doors, and can no longer accept Benevolent Bites credit. You still have $ %.2f of credit there.
+
+		Please visit %s to choose whether you would like this credit refunded to your card, or donated to the restaurant's staff.
+
+		Thanks for supporting your local restaurants,
+		
+			The Benevolent Bites Team
+
+`
@@ -0,0 +1,99 @@
+package photos
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/storage"
+)
+
+// GCSStore keeps photos in a Google Cloud Storage bucket.
+// Objects are not given their own ACLs, so the bucket should use uniform
+// bucket-level access with public read (or sit behind a CDN).
+type GCSStore struct {
+	client  *storage.Client
+	bucket  string
+	baseURL string
+}
+
+// NewGCSStore creates the storage client once, to be shared by every upload
+func NewGCSStore(bucket, baseURL string) (*GCSStore, error) {
+	client, err := storage.NewClient(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	return &GCSStore{client: client, bucket: bucket, baseURL: baseURL}, nil
+}
+
+func (s *GCSStore) Put(key, contentType string, data []byte) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	wc := s.client.Bucket(s.bucket).Object(key).NewWriter(ctx)
+	wc.ContentType = contentType
+	wc.CacheControl = "public, max-age=31536000"
+
+	if _, err := wc.Write(data); err != nil {
+		wc.Close()
+		return "", err
+	}
+	if err := wc.Close(); err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%s/%s", s.baseURL, key), nil
+}
+
+func (s *GCSStore) Get(key string) ([]byte, string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	r, err := s.client.Bucket(s.bucket).Object(key).NewReader(ctx)
+	if err == storage.ErrObjectNotExist {
+		return nil, "", ErrNotFound
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	defer r.Close()
+
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return data, r.ContentType(), nil
+}
+
+func (s *GCSStore) Delete(key string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	err := s.client.Bucket(s.bucket).Object(key).Delete(ctx)
+	if err == storage.ErrObjectNotExist {
+		return nil
+	}
+	return err
+}
+
+// MediaLinkKey finds the object name in a GCS media link, which is how photos
+// were referenced before they had their own records
+func MediaLinkKey(link string) string {
+	u, err := url.Parse(link)
+	if err != nil {
+		return ""
+	}
+	i := strings.LastIndex(u.Path, "/o/")
+	if i < 0 {
+		return ""
+	}
+	key, err := url.PathUnescape(u.Path[i+3:])
+	if err != nil {
+		return ""
+	}
+	return key
+}
@@ -0,0 +1,70 @@
+package photos
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// LocalStore keeps photos on the local disk, for developing and testing offline
+type LocalStore struct {
+	dir     string
+	baseURL string
+}
+
+// NewLocalStore makes sure the photo directory exists
+func NewLocalStore(dir, baseURL string) (*LocalStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &LocalStore{dir: dir, baseURL: strings.TrimRight(baseURL, "/")}, nil
+}
+
+func (s *LocalStore) path(key string) (string, error) {
+	if key == "" || strings.Contains(key, "..") || strings.ContainsAny(key, `/\`) {
+		return "", errors.New("invalid photo key")
+	}
+	return filepath.Join(s.dir, key), nil
+}
+
+func (s *LocalStore) Put(key, contentType string, data []byte) (string, error) {
+	p, err := s.path(key)
+	if err != nil {
+		return "", err
+	}
+	if err := ioutil.WriteFile(p, data, 0644); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s/%s", s.baseURL, key), nil
+}
+
+func (s *LocalStore) Get(key string) ([]byte, string, error) {
+	p, err := s.path(key)
+	if err != nil {
+		return nil, "", err
+	}
+	data, err := ioutil.ReadFile(p)
+	if os.IsNotExist(err) {
+		return nil, "", ErrNotFound
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	return data, http.DetectContentType(data), nil
+}
+
+func (s *LocalStore) Delete(key string) error {
+	p, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(p)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
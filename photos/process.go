@@ -0,0 +1,223 @@
+package photos
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"net/http"
+
+	// Register the formats which can be uploaded
+	_ "image/gif"
+	_ "image/png"
+)
+
+const (
+	// MaxUploadSize is the largest photo file accepted, in bytes
+	MaxUploadSize = 10 << 20
+	// maxPixels guards against small files which decode into huge images
+	maxPixels = 40000000
+
+	ThumbWidth   = 320
+	DisplayWidth = 1280
+	jpegQuality  = 85
+)
+
+// AllowedTypes are the image types which can be uploaded
+var AllowedTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/gif":  true,
+}
+
+var ErrNotImage = errors.New("sorry bro, photos have to be jpeg, png or gif images")
+
+// Variants are the re-encoded versions of an uploaded photo. They're fresh JPEGs,
+// so any EXIF metadata (such as GPS location) from the original is gone.
+type Variants struct {
+	Thumb   []byte
+	Display []byte
+}
+
+// Process validates an uploaded photo and produces its thumbnail and display variants
+func Process(data []byte) (Variants, error) {
+	if len(data) > MaxUploadSize {
+		return Variants{}, fmt.Errorf("sorry bro, photos have to be smaller than %d MB", MaxUploadSize>>20)
+	}
+
+	if !AllowedTypes[http.DetectContentType(data)] {
+		return Variants{}, ErrNotImage
+	}
+
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return Variants{}, ErrNotImage
+	}
+	if cfg.Width*cfg.Height > maxPixels {
+		return Variants{}, errors.New("sorry bro, that photo has too many pixels")
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return Variants{}, ErrNotImage
+	}
+	img = orient(img, jpegOrientation(data))
+
+	// The thumbnail is made from the display variant, which is much quicker to scan
+	display := resize(img, DisplayWidth)
+
+	var v Variants
+	if v.Display, err = encode(display); err != nil {
+		return Variants{}, err
+	}
+	if v.Thumb, err = encode(resize(display, ThumbWidth)); err != nil {
+		return Variants{}, err
+	}
+
+	return v, nil
+}
+
+func encode(img image.Image) ([]byte, error) {
+	var buf bytes.Buffer
+	err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: jpegQuality})
+	return buf.Bytes(), err
+}
+
+// resize shrinks an image to at most width pixels wide, averaging the source
+// pixels which fall into each destination pixel. Images are never enlarged.
+func resize(src image.Image, width int) image.Image {
+	b := src.Bounds()
+	if b.Dx() <= width {
+		width = b.Dx()
+	}
+	height := b.Dy() * width / b.Dx()
+	if height < 1 {
+		height = 1
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		y0 := b.Min.Y + y*b.Dy()/height
+		y1 := b.Min.Y + (y+1)*b.Dy()/height
+		for x := 0; x < width; x++ {
+			x0 := b.Min.X + x*b.Dx()/width
+			x1 := b.Min.X + (x+1)*b.Dx()/width
+
+			var r, g, bl, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := src.At(sx, sy).RGBA()
+					r, g, bl, a = r+uint64(cr), g+uint64(cg), bl+uint64(cb), a+uint64(ca)
+					n++
+				}
+			}
+			if n == 0 {
+				continue
+			}
+			dst.SetRGBA(x, y, color.RGBA{
+				R: uint8(r / n >> 8),
+				G: uint8(g / n >> 8),
+				B: uint8(bl / n >> 8),
+				A: uint8(a / n >> 8),
+			})
+		}
+	}
+
+	return dst
+}
+
+// orient applies an EXIF orientation, since re-encoding drops the tag which told viewers to do it
+func orient(src image.Image, orientation int) image.Image {
+	if orientation < 2 || orientation > 8 {
+		return src
+	}
+
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+			switch orientation {
+			case 2: // mirrored
+				dx, dy = w-1-x, y
+			case 3: // rotated 180
+				dx, dy = w-1-x, h-1-y
+			case 4: // mirrored vertically
+				dx, dy = x, h-1-y
+			case 5: // transposed
+				dx, dy = y, x
+			case 6: // rotated 90 clockwise
+				dx, dy = h-1-y, x
+			case 7: // transversed
+				dx, dy = h-1-y, w-1-x
+			case 8: // rotated 90 counter clockwise
+				dx, dy = y, w-1-x
+			}
+			dst.Set(dx, dy, src.At(b.Min.X+x, b.Min.Y+y))
+		}
+	}
+
+	return dst
+}
+
+// jpegOrientation reads the EXIF orientation tag of a JPEG, returning 1 (normal) if there isn't one
+func jpegOrientation(data []byte) int {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return 1
+	}
+
+	// Walk the JPEG segments looking for the APP1 Exif segment
+	i := 2
+	for i+4 <= len(data) && data[i] == 0xFF {
+		marker := data[i+1]
+		size := int(binary.BigEndian.Uint16(data[i+2:]))
+		if marker == 0xDA || size < 2 || i+2+size > len(data) {
+			return 1
+		}
+		seg := data[i+4 : i+2+size]
+		if marker == 0xE1 && len(seg) > 14 && string(seg[:6]) == "Exif\x00\x00" {
+			return tiffOrientation(seg[6:])
+		}
+		i += 2 + size
+	}
+
+	return 1
+}
+
+func tiffOrientation(tiff []byte) int {
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd+2 > len(tiff) {
+		return 1
+	}
+	entries := int(order.Uint16(tiff[ifd:]))
+	for e := 0; e < entries; e++ {
+		entry := ifd + 2 + e*12
+		if entry+12 > len(tiff) {
+			return 1
+		}
+		if order.Uint16(tiff[entry:]) == 0x0112 {
+			return int(order.Uint16(tiff[entry+8:]))
+		}
+	}
+
+	return 1
+}
@@ -0,0 +1,86 @@
+package photos
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// PhotoStore saves restaurant photos somewhere they can be served from
+type PhotoStore interface {
+	// Put saves an object under key, and returns the public URL it can be fetched from
+	Put(key, contentType string, data []byte) (string, error)
+	// Get reads an object and its content type, returning ErrNotFound if there isn't one
+	Get(key string) ([]byte, string, error)
+	// Delete removes an object
+	Delete(key string) error
+}
+
+// ErrNotFound is returned by Get for objects which don't exist
+var ErrNotFound = errors.New("photo not found")
+
+var (
+	// Store is the backend all photos are saved to, chosen by P_STORE
+	Store PhotoStore
+
+	// LocalDir is where the local backend keeps photos, served under LocalURL
+	LocalDir = "../uploads"
+	LocalURL = "/uploads"
+)
+
+const defaultBucket = "benevolentbites-restaurant-photos"
+
+// Initialize chooses the photo storage backend.
+//
+// P_STORE - "gcs" (default) or "local"
+// P_BUCKET - GCS bucket name
+// P_URL - public base url of stored photos, for when they're served through a CDN
+// P_DIR - directory used by the local backend
+//
+// Without GCS credentials a LOCAL server falls back to the local backend, anywhere
+// else it refuses to start.
+func Initialize() {
+	log.Info("BB: Initializing photo storage")
+
+	if dir := os.Getenv("P_DIR"); dir != "" {
+		LocalDir = dir
+	}
+
+	switch os.Getenv("P_STORE") {
+	case "local":
+		baseURL := LocalURL
+		if u := os.Getenv("P_URL"); u != "" {
+			baseURL = u
+		}
+		store, err := NewLocalStore(LocalDir, baseURL)
+		if err != nil {
+			panic(err)
+		}
+		Store = store
+	default:
+		bucket := os.Getenv("P_BUCKET")
+		if bucket == "" {
+			bucket = defaultBucket
+		}
+		baseURL := os.Getenv("P_URL")
+		if baseURL == "" {
+			baseURL = fmt.Sprintf("https://storage.googleapis.com/%s", bucket)
+		}
+		store, err := NewGCSStore(bucket, baseURL)
+		if err != nil {
+			if os.Getenv("S_ENV") != "LOCAL" {
+				log.Fatal("BB: unable to reach the photo bucket, check the GCS credentials or set P_STORE=local: ", err)
+			}
+			log.Warn("BB: no GCS credentials, keeping photos in ", LocalDir, " instead: ", err)
+			local, err := NewLocalStore(LocalDir, LocalURL)
+			if err != nil {
+				log.Fatal("BB: unable to store photos locally: ", err)
+			}
+			Store = local
+			return
+		}
+		Store = store
+	}
+}
@@ -0,0 +1,67 @@
+package photos
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"mime/multipart"
+
+	"github.com/rishabh-bector/BenevolentBitesBack/auth"
+)
+
+// Upload is a processed photo saved to the store
+type Upload struct {
+	ID         string `json:"id"`
+	DisplayURL string `json:"displayUrl"`
+	ThumbURL   string `json:"thumbUrl"`
+}
+
+// DisplayKey and ThumbKey name the stored variants of a photo
+func DisplayKey(id string) string { return fmt.Sprintf("%s-display.jpg", id) }
+func ThumbKey(id string) string   { return fmt.Sprintf("%s-thumb.jpg", id) }
+
+// SaveUpload validates, processes and stores a single uploaded photo
+func SaveUpload(fh *multipart.FileHeader) (Upload, error) {
+	if fh.Size > MaxUploadSize {
+		return Upload{}, fmt.Errorf("sorry bro, photos have to be smaller than %d MB", MaxUploadSize>>20)
+	}
+
+	f, err := fh.Open()
+	if err != nil {
+		return Upload{}, err
+	}
+	defer f.Close()
+
+	data, err := ioutil.ReadAll(f)
+	if err != nil {
+		return Upload{}, err
+	}
+
+	variants, err := Process(data)
+	if err != nil {
+		return Upload{}, err
+	}
+
+	if Store == nil {
+		return Upload{}, errors.New("photo storage is not initialized")
+	}
+
+	up := Upload{ID: auth.GenerateUUID()}
+	if up.DisplayURL, err = Store.Put(DisplayKey(up.ID), "image/jpeg", variants.Display); err != nil {
+		return Upload{}, err
+	}
+	if up.ThumbURL, err = Store.Put(ThumbKey(up.ID), "image/jpeg", variants.Thumb); err != nil {
+		Store.Delete(DisplayKey(up.ID))
+		return Upload{}, err
+	}
+
+	return up, nil
+}
+
+// DeleteUpload removes every stored variant of a photo
+func DeleteUpload(id string) error {
+	if err := Store.Delete(DisplayKey(id)); err != nil {
+		return err
+	}
+	return Store.Delete(ThumbKey(id))
+}
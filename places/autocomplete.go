@@ -0,0 +1,119 @@
+package places
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+
+	"github.com/rishabh-bector/BenevolentBitesBack/database"
+	log "github.com/sirupsen/logrus"
+)
+
+// AutocompleteLimit is how many suggestions are returned for a single keystroke
+var AutocompleteLimit = 8
+
+// Suggestion is a single autocomplete result
+type Suggestion struct {
+	Name    string `json:"name"`
+	Detail  string `json:"detail"` // Address or area of the place
+	PlaceID string `json:"placeId"`
+	RestID  string `json:"restID"` // Like APIDetails, the restaurant UUID for partners and the place ID otherwise
+	Slug    string `json:"slug,omitempty"`
+	Partner bool   `json:"partner"`
+}
+
+// Autocomplete suggests restaurants for partly typed text. Partner restaurants are
+// ranked first, followed by Google's predictions, which are biased to lat/lng when given.
+// The session token groups the keystrokes of one search for Google's billing.
+func Autocomplete(input, lat, lng, session string) ([]Suggestion, error) {
+	input = strings.TrimSpace(input)
+	if input == "" {
+		return []Suggestion{}, nil
+	}
+	if len(input) > 100 {
+		return nil, errors.New("sorry bro, that's too long to autocomplete")
+	}
+
+	suggestions := []Suggestion{}
+	seen := map[string]bool{}
+
+	rests, err := database.AutocompleteRestaurants(input, AutocompleteLimit)
+	if err != nil {
+		log.Error("BB: unable to autocomplete partner restaurants: ", err)
+	}
+	for _, r := range rests {
+		suggestions = append(suggestions, partnerSuggestion(r, ""))
+		seen[r.PlaceID] = true
+	}
+
+	params := url.Values{
+		"input": {input},
+		"types": {"establishment"},
+	}
+	if lat != "" && lng != "" {
+		params.Set("location", lat+","+lng)
+		params.Set("radius", "50000")
+	}
+	if session != "" {
+		params.Set("sessiontoken", session)
+	}
+
+	predictions, err := Provider.Autocomplete(params)
+	if err != nil {
+		// Our own restaurants are still worth suggesting
+		log.Error(err)
+		return suggestions, nil
+	}
+
+	ids := []string{}
+	for _, p := range predictions {
+		ids = append(ids, p.PlaceID)
+	}
+	partners, err := database.GetRestaurantsByPlaceIDs(ids)
+	if err != nil {
+		log.Error("BB: unable to look up partner restaurants: ", err)
+	}
+
+	// Partners Google found which the name search didn't still go before everything else
+	others := []Suggestion{}
+	for _, p := range predictions {
+		if seen[p.PlaceID] {
+			continue
+		}
+		seen[p.PlaceID] = true
+
+		if r, ok := partners[p.PlaceID]; ok && r.Published && !r.Archived {
+			suggestions = append(suggestions, partnerSuggestion(r, p.StructuredFormatting.SecondaryText))
+			continue
+		}
+
+		others = append(others, Suggestion{
+			Name:    p.StructuredFormatting.MainText,
+			Detail:  p.StructuredFormatting.SecondaryText,
+			PlaceID: p.PlaceID,
+			RestID:  p.PlaceID,
+		})
+	}
+
+	suggestions = append(suggestions, others...)
+	if len(suggestions) > AutocompleteLimit {
+		suggestions = suggestions[:AutocompleteLimit]
+	}
+
+	return suggestions, nil
+}
+
+func partnerSuggestion(r database.Restaurant, detail string) Suggestion {
+	if detail == "" {
+		detail = strings.Trim(strings.Join([]string{r.Address, r.City, r.State}, ", "), ", ")
+	}
+
+	return Suggestion{
+		Name:    r.Name,
+		Detail:  detail,
+		PlaceID: r.PlaceID,
+		RestID:  r.UUID,
+		Slug:    r.Slug,
+		Partner: true,
+	}
+}
@@ -0,0 +1,202 @@
+package places
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/rishabh-bector/BenevolentBitesBack/database"
+	log "github.com/sirupsen/logrus"
+
+	"googlemaps.github.io/maps"
+)
+
+// How long Places responses are cached. Google only allows place IDs to be kept
+// indefinitely, everything else is refreshed well within the 30 days their terms allow.
+var (
+	DetailsTTL = 24 * time.Hour
+	SearchTTL  = 1 * time.Hour
+	FindTTL    = 7 * 24 * time.Hour
+	GeocodeTTL = 7 * 24 * time.Hour
+)
+
+// CachedProvider is a read-through cache in front of another provider.
+// Nearby searches are keyed by their coordinates rounded to about 100m, plus the query.
+type CachedProvider struct {
+	Upstream PlacesProvider
+	Prefix   string // Keeps the entries of different upstream providers apart
+
+	hits   [4]uint64
+	misses [4]uint64
+}
+
+const (
+	cacheDetails = iota
+	cacheSearch
+	cacheFind
+	cacheGeocode
+)
+
+var cacheKinds = [4]string{"details", "search", "find", "geocode"}
+
+// CacheStats counts hits and misses for each kind of query
+type CacheStats struct {
+	Hits   uint64 `json:"hits"`
+	Misses uint64 `json:"misses"`
+}
+
+// NearbySearch returns cached results for the rounded location, asking upstream on a miss.
+// Page tokens are single use, so those requests always go upstream. Tokens also expire
+// long before SearchTTL, so only searches without a next page are cached. The others
+// always go upstream, for a fresh token, so paging through them keeps working.
+func (c *CachedProvider) NearbySearch(params url.Values) (NearbyPage, error) {
+	if params.Get("pagetoken") != "" {
+		return c.Upstream.NearbySearch(params)
+	}
+
+	// Entries under the old "search:" keys could be first pages with their token removed
+	params = roundLocation(params)
+	key := c.Prefix + "nearby:" + params.Encode()
+
+	var page NearbyPage
+	if c.lookup(cacheSearch, key, &page) {
+		return page, nil
+	}
+
+	page, err := c.Upstream.NearbySearch(params)
+	if err != nil || page.NextPageToken != "" {
+		return page, err
+	}
+
+	ids := []string{}
+	for _, r := range page.Results {
+		ids = append(ids, r.PlaceID)
+	}
+	c.store(key, ids, page, SearchTTL)
+
+	return page, nil
+}
+
+// FindPlace returns cached candidates for the same input
+func (c *CachedProvider) FindPlace(input string, fields string) ([]maps.PlacesSearchResult, error) {
+	key := c.Prefix + "find:" + fields + ":" + strings.ToLower(strings.TrimSpace(input))
+
+	var candidates []maps.PlacesSearchResult
+	if c.lookup(cacheFind, key, &candidates) {
+		return candidates, nil
+	}
+
+	candidates, err := c.Upstream.FindPlace(input, fields)
+	if err != nil {
+		return candidates, err
+	}
+
+	ids := []string{}
+	for _, r := range candidates {
+		ids = append(ids, r.PlaceID)
+	}
+	c.store(key, ids, candidates, FindTTL)
+
+	return candidates, nil
+}
+
+// Details returns the cached details of a place
+func (c *CachedProvider) Details(placeID string) (maps.PlaceDetailsResult, error) {
+	key := c.Prefix + "details:" + placeID
+
+	var details maps.PlaceDetailsResult
+	if c.lookup(cacheDetails, key, &details) {
+		return details, nil
+	}
+
+	details, err := c.Upstream.Details(placeID)
+	if err != nil {
+		return details, err
+	}
+	c.store(key, []string{placeID}, details, DetailsTTL)
+
+	return details, nil
+}
+
+// Geocode returns cached places for the same address text
+func (c *CachedProvider) Geocode(address string) ([]maps.GeocodingResult, error) {
+	key := c.Prefix + "geocode:" + strings.ToLower(strings.TrimSpace(address))
+
+	var results []maps.GeocodingResult
+	if c.lookup(cacheGeocode, key, &results) {
+		return results, nil
+	}
+
+	results, err := c.Upstream.Geocode(address)
+	if err != nil {
+		return results, err
+	}
+	c.store(key, nil, results, GeocodeTTL)
+
+	return results, nil
+}
+
+// Autocomplete isn't cached, predictions change with every keystroke and are billed per session
+func (c *CachedProvider) Autocomplete(params url.Values) ([]maps.AutocompletePrediction, error) {
+	return c.Upstream.Autocomplete(params)
+}
+
+// Photo isn't cached here, photos are streamed straight from upstream
+func (c *CachedProvider) Photo(ref string, maxWidth int) (maps.PlacePhotoResponse, int64, error) {
+	return c.Upstream.Photo(ref, maxWidth)
+}
+
+// Stats returns the hit and miss counts since startup
+func (c *CachedProvider) Stats() map[string]CacheStats {
+	stats := map[string]CacheStats{}
+	for i, kind := range cacheKinds {
+		stats[kind] = CacheStats{
+			Hits:   atomic.LoadUint64(&c.hits[i]),
+			Misses: atomic.LoadUint64(&c.misses[i]),
+		}
+	}
+	return stats
+}
+
+func (c *CachedProvider) lookup(kind int, key string, out interface{}) bool {
+	if database.GetPlacesCache(key, out) {
+		atomic.AddUint64(&c.hits[kind], 1)
+		return true
+	}
+	atomic.AddUint64(&c.misses[kind], 1)
+	return false
+}
+
+func (c *CachedProvider) store(key string, placeIDs []string, v interface{}, ttl time.Duration) {
+	err := database.SetPlacesCache(key, placeIDs, v, ttl)
+	if err != nil {
+		log.Error("BB: unable to cache places response: ", err)
+	}
+}
+
+// InvalidatePlace drops everything cached about the given places, e.g. when an owner moves their restaurant
+func InvalidatePlace(placeIDs ...string) {
+	err := database.InvalidatePlacesCache(placeIDs...)
+	if err != nil {
+		log.Error("BB: unable to invalidate places cache: ", err)
+	}
+}
+
+// roundLocation rounds the location parameter to 3 decimal places, so nearby
+// searches share a cache entry. The rounded location is what gets sent to Google.
+func roundLocation(params url.Values) url.Values {
+	lat, lng, err := parseLocation(params.Get("location"))
+	if err != nil {
+		return params
+	}
+
+	rounded := url.Values{}
+	for k, v := range params {
+		rounded[k] = v
+	}
+	rounded.Set("location", fmt.Sprintf("%.3f,%.3f", lat, lng))
+
+	return rounded
+}
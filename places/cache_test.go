@@ -0,0 +1,77 @@
+package places
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/rishabh-bector/BenevolentBitesBack/database"
+
+	"googlemaps.github.io/maps"
+)
+
+// withTestCache points the places cache at a scratch database on the Mongo at M_URL,
+// and returns a func to drop it again. Without M_URL the test is skipped.
+func withTestCache(t *testing.T) func() {
+	if os.Getenv("M_URL") == "" {
+		t.Skip("M_URL isn't set")
+	}
+
+	old := os.Getenv("M_DB")
+	os.Setenv("M_DB", fmt.Sprintf("bb_test_%d", time.Now().UnixNano()))
+	database.Initialize()
+	db := database.Client.Database(os.Getenv("M_DB"))
+	os.Setenv("M_DB", old)
+
+	return func() {
+		db.Drop(context.Background())
+		database.Client.Disconnect(context.Background())
+	}
+}
+
+// pagedProvider answers nearby searches with a next page for keyword "more", counting how often it's asked
+type pagedProvider struct {
+	PlacesProvider
+	calls int
+}
+
+func (p *pagedProvider) NearbySearch(params url.Values) (NearbyPage, error) {
+	p.calls++
+	page := NearbyPage{Results: []maps.PlacesSearchResult{{PlaceID: "ChIJstub"}}}
+	if params.Get("keyword") == "more" {
+		page.NextPageToken = fmt.Sprintf("token-%d", p.calls)
+	}
+	return page, nil
+}
+
+func TestCachedNearbySearchKeepsPaging(t *testing.T) {
+	defer withTestCache(t)()
+
+	upstream := &pagedProvider{}
+	c := &CachedProvider{Upstream: upstream, Prefix: "test:"}
+
+	for i := 0; i < 2; i++ {
+		page, err := c.NearbySearch(url.Values{"keyword": {"more"}, "location": {"30.263,-97.731"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if page.NextPageToken == "" {
+			t.Fatalf("search %d: expected a next page", i+1)
+		}
+	}
+	if upstream.calls != 2 {
+		t.Errorf("expected searches with more pages to go upstream each time, got %d calls", upstream.calls)
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := c.NearbySearch(url.Values{"keyword": {"last"}, "location": {"30.263,-97.731"}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if upstream.calls != 3 {
+		t.Errorf("expected a single page search to be cached, got %d calls", upstream.calls)
+	}
+}
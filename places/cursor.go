@@ -0,0 +1,85 @@
+package places
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"time"
+)
+
+// Google's next_page_token only becomes valid a short while after it is issued
+var (
+	PageTokenDelay   = 2 * time.Second
+	PageTokenRetries = 3
+)
+
+var errCursorExpired = errors.New("sorry bro, that search has expired, please search again")
+
+// searchCursor is what's behind the opaque cursor given to the frontend
+type searchCursor struct {
+	Token   string        `json:"t"`
+	Issued  int64         `json:"i"` // Unix milliseconds
+	Filters SearchFilters `json:"f"`
+	Lat     float64       `json:"la"` // Search origin, for distances
+	Lng     float64       `json:"ln"`
+	Seen    []string      `json:"s,omitempty"` // Partners returned on earlier pages
+}
+
+// newCursor wraps a page token, returning nothing if there are no more pages
+func newCursor(token string, filters SearchFilters, lat, lng float64, on []APIDetails, seen []string) string {
+	if token == "" {
+		return ""
+	}
+
+	cur := searchCursor{
+		Token:   token,
+		Issued:  time.Now().UnixNano() / int64(time.Millisecond),
+		Filters: filters,
+		Lat:     lat,
+		Lng:     lng,
+		Seen:    append([]string{}, seen...),
+	}
+	for _, d := range on {
+		cur.Seen = append(cur.Seen, d.RestID)
+	}
+
+	b, _ := json.Marshal(cur)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeCursor(s string) (searchCursor, error) {
+	var cur searchCursor
+
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || json.Unmarshal(b, &cur) != nil || cur.Token == "" {
+		return cur, errors.New("sorry bro, invalid cursor")
+	}
+
+	return cur, nil
+}
+
+// resolvePageToken fetches the page behind a cursor. If the token isn't active yet, this
+// waits for it, so the frontend can ask for the next page as soon as it gets a cursor.
+func resolvePageToken(cur searchCursor) (NearbyPage, error) {
+	active := time.Unix(0, cur.Issued*int64(time.Millisecond)).Add(PageTokenDelay)
+	if wait := time.Until(active); wait > 0 {
+		time.Sleep(wait)
+	}
+
+	params := url.Values{"pagetoken": {cur.Token}}
+	for i := 0; ; i++ {
+		page, err := Provider.NearbySearch(params)
+
+		// Google answers INVALID_REQUEST both for tokens which aren't active yet, and ones which expired
+		if IsStatus(err, StatusInvalidRequest) {
+			if i >= PageTokenRetries {
+				return NearbyPage{}, errCursorExpired
+			}
+			time.Sleep(time.Second)
+			continue
+		}
+
+		return page, err
+	}
+}
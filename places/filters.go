@@ -0,0 +1,181 @@
+package places
+
+import (
+	"errors"
+	"math"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Ways search results can be sorted. Without a sort, results keep Google's order.
+const (
+	SortDistance = "distance"
+	SortRating   = "rating"
+	SortPartner  = "partner" // Partners running a campaign first, then those selling, then by distance
+)
+
+// SearchFilters narrow down the restaurants returned by a search. They apply to both the
+// On and Off lists, so restaurants which aren't on Benevolent Bites never match a filter
+// only partners can have, like tags or campaigns.
+type SearchFilters struct {
+	Cuisines       []string `json:"c,omitempty"`
+	Dietary        []string `json:"d,omitempty"`
+	Price          []int    `json:"p,omitempty"` // Google price levels, 0 (free) to 4 (very expensive)
+	MinRating      float32  `json:"r,omitempty"`
+	OpenNow        bool     `json:"o,omitempty"`
+	OnPlatformOnly bool     `json:"on,omitempty"`
+	HasCampaign    bool     `json:"hc,omitempty"`
+	Sort           string   `json:"s,omitempty"`
+}
+
+// Validate checks the filters make sense
+func (f SearchFilters) Validate() error {
+	for _, p := range f.Price {
+		if p < 0 || p > 4 {
+			return errors.New("sorry bro, price levels go from 0 to 4")
+		}
+	}
+	if f.MinRating < 0 || f.MinRating > 5 {
+		return errors.New("sorry bro, ratings go from 0 to 5")
+	}
+	switch f.Sort {
+	case "", SortDistance, SortRating, SortPartner:
+	default:
+		return errors.New("sorry bro, results can only be sorted by distance, rating or partner")
+	}
+	return nil
+}
+
+func (f SearchFilters) hasTags() bool {
+	return len(f.Cuisines) > 0 || len(f.Dietary) > 0
+}
+
+// partnersOnly reports whether only restaurants on Benevolent Bites can match
+func (f SearchFilters) partnersOnly() bool {
+	return f.hasTags() || f.HasCampaign || f.OnPlatformOnly
+}
+
+// needsDetails reports whether filtering or sorting needs something only Google's details have
+func (f SearchFilters) needsDetails() bool {
+	return len(f.Price) > 0 || f.MinRating > 0 || f.OpenNow || f.Sort == SortRating
+}
+
+// googleParams narrows down the nearby search itself, so a page isn't mostly filtered out
+func (f SearchFilters) googleParams(params url.Values) {
+	if f.OpenNow {
+		params.Set("opennow", "true")
+	}
+	if len(f.Price) > 0 {
+		min, max := 4, 0
+		for _, p := range f.Price {
+			if p < min {
+				min = p
+			}
+			if p > max {
+				max = p
+			}
+		}
+		params.Set("minprice", strconv.Itoa(min))
+		params.Set("maxprice", strconv.Itoa(max))
+	}
+}
+
+// match reports whether a single result passes every filter
+func (f SearchFilters) match(d APIDetails, partner bool) bool {
+	if !partner && f.partnersOnly() {
+		return false
+	}
+	if !hasAllTags(f.Cuisines, d.Cuisines) || !hasAllTags(f.Dietary, d.Dietary) {
+		return false
+	}
+	if f.HasCampaign && !d.Campaign {
+		return false
+	}
+	if len(f.Price) > 0 && !intInSlice(d.PriceLevel, f.Price) {
+		return false
+	}
+	if d.Rating < f.MinRating {
+		return false
+	}
+	if f.OpenNow && (d.OpenNow == nil || !*d.OpenNow) {
+		return false
+	}
+	return true
+}
+
+// apply filters and sorts both lists of a search response
+func (f SearchFilters) apply(sr *SearchResponse) {
+	sr.On = f.filter(sr.On, true)
+	sr.Off = f.filter(sr.Off, false)
+
+	sortResults(sr.On, f.Sort)
+	sortResults(sr.Off, f.Sort)
+}
+
+func (f SearchFilters) filter(list []APIDetails, partner bool) []APIDetails {
+	out := []APIDetails{}
+	for _, d := range list {
+		if f.match(d, partner) {
+			out = append(out, d)
+		}
+	}
+	return out
+}
+
+// sortResults sorts a list of results in place, keeping the original order for ties
+func sortResults(list []APIDetails, by string) {
+	switch by {
+	case SortDistance:
+		sort.SliceStable(list, func(i, j int) bool {
+			return list[i].Distance < list[j].Distance
+		})
+	case SortRating:
+		sort.SliceStable(list, func(i, j int) bool {
+			return list[i].Rating > list[j].Rating
+		})
+	case SortPartner:
+		sort.SliceStable(list, func(i, j int) bool {
+			if list[i].Campaign != list[j].Campaign {
+				return list[i].Campaign
+			}
+			if list[i].Paused != list[j].Paused {
+				return !list[i].Paused
+			}
+			return list[i].Distance < list[j].Distance
+		})
+	}
+}
+
+// distance returns the great circle distance between two points in meters
+func distance(lat1, lng1, lat2, lng2 float64) float64 {
+	const earthRadius = 6371000
+
+	rad := math.Pi / 180
+	dLat := (lat2 - lat1) * rad
+	dLng := (lng2 - lng1) * rad
+
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1*rad)*math.Cos(lat2*rad)*math.Sin(dLng/2)*math.Sin(dLng/2)
+
+	return math.Round(2 * earthRadius * math.Asin(math.Sqrt(a)))
+}
+
+func hasAllTags(want, have []string) bool {
+	for _, t := range want {
+		if !stringInSlice(strings.ToLower(strings.TrimSpace(t)), have) {
+			return false
+		}
+	}
+	return true
+}
+
+func intInSlice(a int, list []int) bool {
+	for _, b := range list {
+		if b == a {
+			return true
+		}
+	}
+	return false
+}
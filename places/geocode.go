@@ -0,0 +1,69 @@
+package places
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+
+	"googlemaps.github.io/maps"
+)
+
+// AddressSearchResponse is a search around a geocoded address, with where the address is
+type AddressSearchResponse struct {
+	SearchResponse
+	Address  string            `json:"address"` // The address as it was understood
+	Center   maps.LatLng       `json:"center"`
+	Viewport maps.LatLngBounds `json:"viewport"`
+}
+
+// Geocode finds where free text such as a zip code, city or street address is
+func Geocode(address string) (maps.GeocodingResult, error) {
+	address = strings.TrimSpace(address)
+	if address == "" {
+		return maps.GeocodingResult{}, errors.New("sorry bro, no address given")
+	}
+
+	results, err := Provider.Geocode(address)
+	if err != nil {
+		return maps.GeocodingResult{}, err
+	}
+	if len(results) == 0 {
+		return maps.GeocodingResult{}, errors.New("sorry bro, unable to find that address")
+	}
+
+	return results[0], nil
+}
+
+// SearchAddress geocodes free text, then runs the same search as SearchCoords around it.
+// Without a range, the search covers the viewport of the address, e.g. a whole city.
+func SearchAddress(address, query string, rngMiles float64, view string, filters SearchFilters) (AddressSearchResponse, error) {
+	place, err := Geocode(address)
+	if err != nil {
+		return AddressSearchResponse{}, err
+	}
+
+	center := place.Geometry.Location
+	viewport := place.Geometry.Viewport
+
+	if rngMiles <= 0 {
+		ne, sw := viewport.NorthEast, viewport.SouthWest
+		rngMiles = distance(sw.Lat, sw.Lng, ne.Lat, ne.Lng) / 2 / 1600
+		rngMiles = math.Max(1, math.Min(rngMiles, 50000/1600.0))
+	}
+
+	lat := strconv.FormatFloat(center.Lat, 'f', -1, 64)
+	lng := strconv.FormatFloat(center.Lng, 'f', -1, 64)
+
+	sr, err := SearchCoords(query, lat, lng, rngMiles, view, filters)
+	if err != nil {
+		return AddressSearchResponse{}, err
+	}
+
+	return AddressSearchResponse{
+		SearchResponse: sr,
+		Address:        place.FormattedAddress,
+		Center:         center,
+		Viewport:       viewport,
+	}, nil
+}
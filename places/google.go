@@ -0,0 +1,227 @@
+package places
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"googlemaps.github.io/maps"
+)
+
+// Statuses returned by the Google Places web service
+const (
+	StatusOK             = "OK"
+	StatusZeroResults    = "ZERO_RESULTS"
+	StatusOverQueryLimit = "OVER_QUERY_LIMIT"
+	StatusRequestDenied  = "REQUEST_DENIED"
+	StatusInvalidRequest = "INVALID_REQUEST"
+	StatusNotFound       = "NOT_FOUND"
+	StatusUnknownError   = "UNKNOWN_ERROR"
+)
+
+// StatusError is returned when Google answers a request with anything but OK.
+// Other providers use the same statuses.
+type StatusError struct {
+	Status  string `json:"status"`
+	Message string `json:"message,omitempty"`
+}
+
+func (e *StatusError) Error() string {
+	if e.Message != "" {
+		return fmt.Sprintf("places: %s: %s", e.Status, e.Message)
+	}
+	return fmt.Sprintf("places: %s", e.Status)
+}
+
+// Temporary reports whether the same request might succeed if it is sent again
+func (e *StatusError) Temporary() bool {
+	return e.Status == StatusOverQueryLimit || e.Status == StatusUnknownError
+}
+
+// IsStatus reports whether err is a StatusError with the given status
+func IsStatus(err error, status string) bool {
+	var se *StatusError
+	return errors.As(err, &se) && se.Status == status
+}
+
+// GoogleClient talks to the Google Places web service
+type GoogleClient struct {
+	Key     string
+	BaseURL string
+	HTTP    *http.Client
+	Retries int           // Extra attempts for temporary failures
+	Backoff time.Duration // Wait before the first retry, doubled for each one after
+}
+
+// NewGoogleClient creates a client with sensible timeouts and retries
+func NewGoogleClient(key string) *GoogleClient {
+	return &GoogleClient{
+		Key:     key,
+		BaseURL: "https://maps.googleapis.com/maps/api",
+		HTTP:    &http.Client{Timeout: 10 * time.Second},
+		Retries: 2,
+		Backoff: 500 * time.Millisecond,
+	}
+}
+
+// NearbyPage is a single page of nearby search results
+type NearbyPage struct {
+	Results       []maps.PlacesSearchResult `json:"results"`
+	NextPageToken string                    `json:"next_page_token"`
+}
+
+// NearbySearch runs a nearbysearch with the given parameters, or fetches the page for a pagetoken
+func (g *GoogleClient) NearbySearch(params url.Values) (NearbyPage, error) {
+	var page NearbyPage
+	err := g.call("/place/nearbysearch/json", params, &page, true)
+	return page, err
+}
+
+// FindPlace runs a findplacefromtext query, returning its candidates
+func (g *GoogleClient) FindPlace(input string, fields string) ([]maps.PlacesSearchResult, error) {
+	params := url.Values{
+		"input":     {input},
+		"inputtype": {"textquery"},
+		"fields":    {fields},
+	}
+
+	var res struct {
+		Candidates []maps.PlacesSearchResult `json:"candidates"`
+	}
+	err := g.call("/place/findplacefromtext/json", params, &res, true)
+	return res.Candidates, err
+}
+
+// Details fetches the details of a place
+func (g *GoogleClient) Details(placeID string) (maps.PlaceDetailsResult, error) {
+	params := url.Values{"place_id": {placeID}}
+
+	var res struct {
+		Result maps.PlaceDetailsResult `json:"result"`
+	}
+	err := g.call("/place/details/json", params, &res, false)
+	return res.Result, err
+}
+
+// Autocomplete returns predictions for partly typed text, e.g. a restaurant name
+func (g *GoogleClient) Autocomplete(params url.Values) ([]maps.AutocompletePrediction, error) {
+	var res struct {
+		Predictions []maps.AutocompletePrediction `json:"predictions"`
+	}
+	err := g.call("/place/autocomplete/json", params, &res, true)
+	return res.Predictions, err
+}
+
+// Geocode turns free text such as a zip code, city or street address into places
+func (g *GoogleClient) Geocode(address string) ([]maps.GeocodingResult, error) {
+	params := url.Values{"address": {address}}
+
+	var res struct {
+		Results []maps.GeocodingResult `json:"results"`
+	}
+	err := g.call("/geocode/json", params, &res, true)
+	return res.Results, err
+}
+
+// Photo streams a place photo. The caller has to close the returned Data.
+func (g *GoogleClient) Photo(ref string, maxWidth int) (maps.PlacePhotoResponse, int64, error) {
+	params := url.Values{
+		"photoreference": {ref},
+		"maxwidth":       {strconv.Itoa(maxWidth)},
+	}
+
+	var res *http.Response
+	err := g.retry(func() (bool, error) {
+		var err error
+		res, err = g.get("/place/photo", params)
+		if err != nil {
+			return true, err
+		}
+		if res.StatusCode != 200 {
+			res.Body.Close()
+			return res.StatusCode >= 500, fmt.Errorf("google places: photo returned status %d", res.StatusCode)
+		}
+		return false, nil
+	})
+	if err != nil {
+		return maps.PlacePhotoResponse{}, 0, err
+	}
+
+	return maps.PlacePhotoResponse{
+		ContentType: res.Header.Get("Content-Type"),
+		Data:        res.Body,
+	}, res.ContentLength, nil
+}
+
+// call sends a request to a JSON endpoint and decodes the response into out.
+// Unless zeroOK is set, ZERO_RESULTS is returned as an error.
+func (g *GoogleClient) call(path string, params url.Values, out interface{}, zeroOK bool) error {
+	return g.retry(func() (bool, error) {
+		res, err := g.get(path, params)
+		if err != nil {
+			return true, err
+		}
+		defer res.Body.Close()
+
+		body, err := ioutil.ReadAll(res.Body)
+		if err != nil {
+			return true, err
+		}
+		if res.StatusCode != 200 {
+			return res.StatusCode >= 500, fmt.Errorf("google places: %s returned status %d", path, res.StatusCode)
+		}
+
+		var envelope struct {
+			Status       string `json:"status"`
+			ErrorMessage string `json:"error_message"`
+		}
+		if err := json.Unmarshal(body, &envelope); err != nil {
+			return false, fmt.Errorf("google places: invalid response from %s: %s", path, err.Error())
+		}
+
+		switch {
+		case envelope.Status == StatusOK, envelope.Status == StatusZeroResults && zeroOK:
+		default:
+			se := &StatusError{Status: envelope.Status, Message: envelope.ErrorMessage}
+			return se.Temporary(), se
+		}
+
+		if err := json.Unmarshal(body, out); err != nil {
+			return false, fmt.Errorf("google places: invalid response from %s: %s", path, err.Error())
+		}
+		return false, nil
+	})
+}
+
+// get sends a single GET request, adding the API key
+func (g *GoogleClient) get(path string, params url.Values) (*http.Response, error) {
+	q := url.Values{}
+	for k, v := range params {
+		q[k] = v
+	}
+	q.Set("key", g.Key)
+
+	return g.HTTP.Get(fmt.Sprintf("%s%s?%s", g.BaseURL, path, q.Encode()))
+}
+
+// retry runs attempt until it succeeds, fails permanently, or runs out of retries
+func (g *GoogleClient) retry(attempt func() (temporary bool, err error)) error {
+	wait := g.Backoff
+	for i := 0; ; i++ {
+		temporary, err := attempt()
+		if err == nil || !temporary || i >= g.Retries {
+			return err
+		}
+
+		log.Info("BB: retrying google places request after error: ", err)
+		time.Sleep(wait)
+		wait *= 2
+	}
+}
@@ -0,0 +1,363 @@
+package places
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"googlemaps.github.io/maps"
+)
+
+// OSM answers place lookups from OpenStreetMap, through Nominatim for geocoding and
+// Overpass for nearby restaurants. Place IDs look like "osm:node/123", so restaurants
+// registered while using Google keep Google place IDs and won't match.
+type OSM struct {
+	NominatimURL string
+	OverpassURL  string
+	UserAgent    string        // Nominatim requires an identifying user agent
+	Interval     time.Duration // Least time between Nominatim requests, the public server allows one a second
+	HTTP         *http.Client
+	Retries      int
+	Backoff      time.Duration
+
+	mu   sync.Mutex
+	last time.Time
+}
+
+// OSMAmenities are the kinds of places a nearby search returns
+var OSMAmenities = []string{"restaurant", "cafe", "bar", "bakery"}
+
+// ErrNoPhotos is returned by providers which don't have place photos
+var ErrNoPhotos = errors.New("sorry bro, no photos are available for this place")
+
+// NewOSM creates an OpenStreetMap provider using the public Nominatim and Overpass servers
+func NewOSM() *OSM {
+	return &OSM{
+		NominatimURL: "https://nominatim.openstreetmap.org",
+		OverpassURL:  "https://overpass-api.de/api/interpreter",
+		UserAgent:    "BenevolentBites/1.0",
+		Interval:     time.Second,
+		HTTP:         &http.Client{Timeout: 30 * time.Second},
+		Retries:      2,
+		Backoff:      time.Second,
+	}
+}
+
+// osmElement is a node, way or relation returned by Overpass
+type osmElement struct {
+	Type   string                      `json:"type"`
+	ID     int64                       `json:"id"`
+	Lat    float64                     `json:"lat"`
+	Lon    float64                     `json:"lon"`
+	Center *struct{ Lat, Lon float64 } `json:"center"`
+	Tags   map[string]string           `json:"tags"`
+}
+
+// nominatimPlace is a single Nominatim search or lookup result
+type nominatimPlace struct {
+	OSMType     string            `json:"osm_type"`
+	OSMID       int64             `json:"osm_id"`
+	Lat         string            `json:"lat"`
+	Lon         string            `json:"lon"`
+	Name        string            `json:"name"`
+	DisplayName string            `json:"display_name"`
+	Category    string            `json:"category"`
+	Type        string            `json:"type"`
+	BoundingBox []string          `json:"boundingbox"` // South, north, west, east
+	ExtraTags   map[string]string `json:"extratags"`
+}
+
+// NearbySearch finds restaurants, cafes, bars and bakeries around the location parameter.
+// All results fit on one page, so there is never a next page token.
+func (o *OSM) NearbySearch(params url.Values) (NearbyPage, error) {
+	lat, lng, err := parseLocation(params.Get("location"))
+	if err != nil {
+		return NearbyPage{}, &StatusError{Status: StatusInvalidRequest, Message: err.Error()}
+	}
+
+	radius := 5000.0
+	if r, err := strconv.ParseFloat(params.Get("radius"), 64); err == nil && r > 0 {
+		radius = math.Min(r, 50000)
+	}
+
+	filter := fmt.Sprintf(`["amenity"~"^(%s)$"]`, strings.Join(OSMAmenities, "|"))
+	if kw := strings.TrimSpace(params.Get("keyword")); kw != "" {
+		filter += fmt.Sprintf(`["name"~"%s",i]`, overpassEscape(regexp.QuoteMeta(kw)))
+	}
+	query := fmt.Sprintf(`[out:json][timeout:25];nwr%s(around:%.0f,%f,%f);out center 60;`, filter, radius, lat, lng)
+
+	body, err := o.get(o.OverpassURL + "?" + url.Values{"data": {query}}.Encode())
+	if err != nil {
+		return NearbyPage{}, err
+	}
+
+	var res struct {
+		Elements []osmElement `json:"elements"`
+	}
+	if err := json.Unmarshal(body, &res); err != nil {
+		return NearbyPage{}, fmt.Errorf("overpass: invalid response: %s", err.Error())
+	}
+
+	page := NearbyPage{Results: []maps.PlacesSearchResult{}}
+	for _, e := range res.Elements {
+		if e.Tags["name"] == "" {
+			continue
+		}
+		page.Results = append(page.Results, e.result())
+	}
+
+	// Overpass has no ranking of its own, so results are always nearest first
+	sort.SliceStable(page.Results, func(i, j int) bool {
+		a, b := page.Results[i].Geometry.Location, page.Results[j].Geometry.Location
+		return distance(lat, lng, a.Lat, a.Lng) < distance(lat, lng, b.Lat, b.Lng)
+	})
+
+	return page, nil
+}
+
+// FindPlace searches Nominatim for the best match of the input
+func (o *OSM) FindPlace(input string, fields string) ([]maps.PlacesSearchResult, error) {
+	found, err := o.search(url.Values{"q": {input}, "limit": {"1"}})
+	if err != nil {
+		return nil, err
+	}
+
+	candidates := []maps.PlacesSearchResult{}
+	for _, p := range found {
+		candidates = append(candidates, maps.PlacesSearchResult{
+			Name:             p.Name,
+			FormattedAddress: p.DisplayName,
+			PlaceID:          p.placeID(),
+			Geometry:         maps.AddressGeometry{Location: p.location()},
+			Types:            []string{p.Type},
+		})
+	}
+
+	return candidates, nil
+}
+
+// Details looks up a place by its OSM place ID
+func (o *OSM) Details(placeID string) (maps.PlaceDetailsResult, error) {
+	id, ok := nominatimID(placeID)
+	if !ok {
+		return maps.PlaceDetailsResult{}, &StatusError{Status: StatusInvalidRequest, Message: "not an OpenStreetMap place ID"}
+	}
+
+	params := url.Values{"osm_ids": {id}, "format": {"jsonv2"}, "extratags": {"1"}}
+	o.throttle()
+	body, err := o.get(o.NominatimURL + "/lookup?" + params.Encode())
+	if err != nil {
+		return maps.PlaceDetailsResult{}, err
+	}
+
+	var found []nominatimPlace
+	if err := json.Unmarshal(body, &found); err != nil {
+		return maps.PlaceDetailsResult{}, fmt.Errorf("nominatim: invalid response: %s", err.Error())
+	}
+	if len(found) == 0 {
+		return maps.PlaceDetailsResult{}, &StatusError{Status: StatusNotFound}
+	}
+
+	p := found[0]
+	return maps.PlaceDetailsResult{
+		Name:                     p.Name,
+		FormattedAddress:         p.DisplayName,
+		PlaceID:                  p.placeID(),
+		Geometry:                 maps.AddressGeometry{Location: p.location(), Viewport: p.viewport()},
+		Types:                    []string{p.Type},
+		Website:                  p.ExtraTags["website"],
+		InternationalPhoneNumber: p.ExtraTags["phone"],
+	}, nil
+}
+
+// Geocode searches Nominatim for free text such as a zip code, city or street address
+func (o *OSM) Geocode(address string) ([]maps.GeocodingResult, error) {
+	found, err := o.search(url.Values{"q": {address}, "limit": {"1"}})
+	if err != nil {
+		return nil, err
+	}
+
+	results := []maps.GeocodingResult{}
+	for _, p := range found {
+		results = append(results, maps.GeocodingResult{
+			FormattedAddress: p.DisplayName,
+			PlaceID:          p.placeID(),
+			Geometry:         maps.AddressGeometry{Location: p.location(), Viewport: p.viewport()},
+			Types:            []string{p.Type},
+		})
+	}
+
+	return results, nil
+}
+
+// Autocomplete never predicts anything. Nominatim's usage policy forbids type-ahead searches,
+// so with OpenStreetMap only partner restaurants are suggested.
+func (o *OSM) Autocomplete(params url.Values) ([]maps.AutocompletePrediction, error) {
+	return []maps.AutocompletePrediction{}, nil
+}
+
+// Photo always fails, OpenStreetMap has no place photos
+func (o *OSM) Photo(ref string, maxWidth int) (maps.PlacePhotoResponse, int64, error) {
+	return maps.PlacePhotoResponse{}, 0, ErrNoPhotos
+}
+
+func (o *OSM) search(params url.Values) ([]nominatimPlace, error) {
+	params.Set("format", "jsonv2")
+
+	o.throttle()
+	body, err := o.get(o.NominatimURL + "/search?" + params.Encode())
+	if err != nil {
+		return nil, err
+	}
+
+	var found []nominatimPlace
+	if err := json.Unmarshal(body, &found); err != nil {
+		return nil, fmt.Errorf("nominatim: invalid response: %s", err.Error())
+	}
+
+	return found, nil
+}
+
+// throttle waits until Interval has passed since the last Nominatim request
+func (o *OSM) throttle() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if wait := time.Until(o.last.Add(o.Interval)); wait > 0 {
+		time.Sleep(wait)
+	}
+	o.last = time.Now()
+}
+
+// get sends a GET request, retrying when the server is overloaded
+func (o *OSM) get(u string) ([]byte, error) {
+	wait := o.Backoff
+	for i := 0; ; i++ {
+		body, status, err := o.getOnce(u)
+		if err == nil && status == 200 {
+			return body, nil
+		}
+
+		temporary := err != nil || status == 429 || status >= 500
+		if err == nil {
+			err = fmt.Errorf("openstreetmap: request returned status %d", status)
+		}
+		if !temporary || i >= o.Retries {
+			return nil, err
+		}
+
+		log.Info("BB: retrying openstreetmap request after error: ", err)
+		time.Sleep(wait)
+		wait *= 2
+	}
+}
+
+func (o *OSM) getOnce(u string) ([]byte, int, error) {
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	req.Header.Set("User-Agent", o.UserAgent)
+
+	res, err := o.HTTP.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer res.Body.Close()
+
+	body, err := ioutil.ReadAll(res.Body)
+	return body, res.StatusCode, err
+}
+
+func (e osmElement) result() maps.PlacesSearchResult {
+	lat, lng := e.Lat, e.Lon
+	if e.Center != nil {
+		lat, lng = e.Center.Lat, e.Center.Lon
+	}
+
+	street := strings.TrimSpace(e.Tags["addr:housenumber"] + " " + e.Tags["addr:street"])
+	vicinity := strings.Trim(strings.Join([]string{street, e.Tags["addr:city"]}, ", "), ", ")
+
+	return maps.PlacesSearchResult{
+		Name:     e.Tags["name"],
+		Vicinity: vicinity,
+		PlaceID:  fmt.Sprintf("osm:%s/%d", e.Type, e.ID),
+		Geometry: maps.AddressGeometry{Location: maps.LatLng{Lat: lat, Lng: lng}},
+		Types:    []string{e.Tags["amenity"]},
+	}
+}
+
+func (p nominatimPlace) placeID() string {
+	return fmt.Sprintf("osm:%s/%d", p.OSMType, p.OSMID)
+}
+
+func (p nominatimPlace) location() maps.LatLng {
+	lat, _ := strconv.ParseFloat(p.Lat, 64)
+	lng, _ := strconv.ParseFloat(p.Lon, 64)
+	return maps.LatLng{Lat: lat, Lng: lng}
+}
+
+func (p nominatimPlace) viewport() maps.LatLngBounds {
+	if len(p.BoundingBox) != 4 {
+		return maps.LatLngBounds{NorthEast: p.location(), SouthWest: p.location()}
+	}
+
+	box := make([]float64, 4)
+	for i, s := range p.BoundingBox {
+		box[i], _ = strconv.ParseFloat(s, 64)
+	}
+
+	return maps.LatLngBounds{
+		NorthEast: maps.LatLng{Lat: box[1], Lng: box[3]},
+		SouthWest: maps.LatLng{Lat: box[0], Lng: box[2]},
+	}
+}
+
+// nominatimID turns "osm:node/123" into Nominatim's "N123"
+func nominatimID(placeID string) (string, bool) {
+	parts := strings.SplitN(strings.TrimPrefix(placeID, "osm:"), "/", 2)
+	if !strings.HasPrefix(placeID, "osm:") || len(parts) != 2 || parts[0] == "" {
+		return "", false
+	}
+	if _, err := strconv.ParseInt(parts[1], 10, 64); err != nil {
+		return "", false
+	}
+
+	switch parts[0] {
+	case "node", "way", "relation":
+		return strings.ToUpper(parts[0][:1]) + parts[1], true
+	}
+	return "", false
+}
+
+func parseLocation(s string) (float64, float64, error) {
+	parts := strings.Split(s, ",")
+	if len(parts) != 2 {
+		return 0, 0, errors.New("invalid location")
+	}
+
+	lat, err1 := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+	lng, err2 := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+	if err1 != nil || err2 != nil {
+		return 0, 0, errors.New("invalid location")
+	}
+
+	return lat, lng, nil
+}
+
+// overpassEscape escapes a string for use inside a double quoted Overpass QL string
+func overpassEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s)
+}
@@ -0,0 +1,167 @@
+package places
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// osmServer answers like Nominatim and Overpass from fixed responses, and counts requests
+type osmServer struct {
+	*httptest.Server
+	requests int32
+	failures int32 // Answers this many requests with 429 first
+}
+
+func newOSMServer(t *testing.T) *osmServer {
+	s := &osmServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&s.requests, 1)
+		if r.Header.Get("User-Agent") != "BenevolentBitesTest" {
+			t.Errorf("expected the configured user agent, got %q", r.Header.Get("User-Agent"))
+		}
+		if atomic.AddInt32(&s.failures, -1) >= 0 {
+			w.WriteHeader(429)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/interpreter":
+			if !strings.Contains(r.URL.Query().Get("data"), `["name"~"taco",i]`) {
+				t.Errorf("expected the keyword in the query, got %s", r.URL.Query().Get("data"))
+			}
+			w.Write([]byte(`{"elements": [
+				{"type": "node", "id": 2, "lat": 30.27, "lon": -97.74, "tags": {"name": "Far Tacos", "amenity": "restaurant"}},
+				{"type": "way", "id": 1, "center": {"lat": 30.2631, "lon": -97.7318},
+				 "tags": {"name": "Near Tacos", "amenity": "restaurant", "addr:housenumber": "1100", "addr:street": "East 6th Street", "addr:city": "Austin"}},
+				{"type": "node", "id": 3, "lat": 30.2632, "lon": -97.7319, "tags": {"amenity": "cafe"}}
+			]}`))
+		case "/lookup":
+			if r.URL.Query().Get("osm_ids") != "W1" {
+				w.Write([]byte(`[]`))
+				return
+			}
+			w.Write([]byte(`[{"osm_type": "way", "osm_id": 1, "lat": "30.2631", "lon": "-97.7318", "name": "Near Tacos",
+				"display_name": "Near Tacos, 1100, East 6th Street, Austin", "category": "amenity", "type": "restaurant",
+				"boundingbox": ["30.2630", "30.2632", "-97.7319", "-97.7317"],
+				"extratags": {"website": "https://example.com/tacos", "phone": "+1 512 555 0142"}}]`))
+		case "/search":
+			w.Write([]byte(`[{"osm_type": "relation", "osm_id": 9, "lat": "30.2638", "lon": "-97.7143",
+				"display_name": "78702, Austin, Texas", "category": "place", "type": "postcode",
+				"boundingbox": ["30.2444", "30.2867", "-97.7372", "-97.6917"]}]`))
+		default:
+			w.WriteHeader(404)
+		}
+	}))
+	return s
+}
+
+func testOSM(s *osmServer) *OSM {
+	o := NewOSM()
+	o.NominatimURL = s.URL
+	o.OverpassURL = s.URL + "/interpreter"
+	o.UserAgent = "BenevolentBitesTest"
+	o.Interval = 0
+	o.Backoff = time.Millisecond
+	return o
+}
+
+func TestOSMNearbySearch(t *testing.T) {
+	s := newOSMServer(t)
+	defer s.Close()
+
+	page, err := testOSM(s).NearbySearch(url.Values{"location": {"30.263,-97.731"}, "keyword": {"taco"}, "radius": {"1000"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Unnamed places are left out, the rest are nearest first
+	if len(page.Results) != 2 || page.NextPageToken != "" {
+		t.Fatalf("expected 2 results on one page, got %+v", page)
+	}
+	near := page.Results[0]
+	if near.PlaceID != "osm:way/1" || near.Vicinity != "1100 East 6th Street, Austin" || near.Geometry.Location.Lat != 30.2631 {
+		t.Errorf("unexpected nearest result %+v", near)
+	}
+	if page.Results[1].Name != "Far Tacos" {
+		t.Errorf("expected Far Tacos second, got %s", page.Results[1].Name)
+	}
+}
+
+func TestOSMDetails(t *testing.T) {
+	s := newOSMServer(t)
+	defer s.Close()
+	o := testOSM(s)
+
+	d, err := o.Details("osm:way/1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Name != "Near Tacos" || d.Website != "https://example.com/tacos" || d.Geometry.Viewport.NorthEast.Lat != 30.2632 {
+		t.Errorf("unexpected details %+v", d)
+	}
+
+	if _, err := o.Details("osm:node/404"); !IsStatus(err, StatusNotFound) {
+		t.Errorf("expected NOT_FOUND, got %v", err)
+	}
+
+	before := atomic.LoadInt32(&s.requests)
+	if _, err := o.Details("ChIJgooglePlace"); !IsStatus(err, StatusInvalidRequest) {
+		t.Errorf("expected INVALID_REQUEST for a Google place ID, got %v", err)
+	}
+	if atomic.LoadInt32(&s.requests) != before {
+		t.Error("a Google place ID was sent to Nominatim")
+	}
+}
+
+func TestOSMGeocodeRetries(t *testing.T) {
+	s := newOSMServer(t)
+	defer s.Close()
+	s.failures = 2
+
+	results, err := testOSM(s).Geocode("78702")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].PlaceID != "osm:relation/9" || results[0].Geometry.Viewport.SouthWest.Lng != -97.7372 {
+		t.Errorf("unexpected results %+v", results)
+	}
+	if n := atomic.LoadInt32(&s.requests); n != 3 {
+		t.Errorf("expected 2 retries, got %d requests", n)
+	}
+}
+
+func TestOSMAutocompleteStaysOffline(t *testing.T) {
+	s := newOSMServer(t)
+	defer s.Close()
+
+	predictions, err := testOSM(s).Autocomplete(url.Values{"input": {"tac"}})
+	if err != nil || len(predictions) != 0 {
+		t.Errorf("expected no predictions, got %+v, %v", predictions, err)
+	}
+	if n := atomic.LoadInt32(&s.requests); n != 0 {
+		t.Errorf("expected no requests to Nominatim, got %d", n)
+	}
+}
+
+func TestOSMThrottle(t *testing.T) {
+	s := newOSMServer(t)
+	defer s.Close()
+	o := testOSM(s)
+	o.Interval = 50 * time.Millisecond
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if _, err := o.Geocode("78702"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("expected Nominatim requests to be spaced out, 3 took %s", elapsed)
+	}
+}
@@ -0,0 +1,212 @@
+package places
+
+import (
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rishabh-bector/BenevolentBitesBack/database"
+	log "github.com/sirupsen/logrus"
+)
+
+// PartnerPage is a page of partner restaurants from SearchPartners
+type PartnerPage struct {
+	Results []APIDetails `json:"results"`
+	Page    int          `json:"page"`
+	More    bool         `json:"more"`
+}
+
+// SearchPartners finds published partner restaurants within rngMiles of a point, nearest first.
+// Pages start at 1. Google is only asked about them when filtering or sorting needs its rating,
+// price or opening hours. Those filters apply after paging, so such a page can come back short.
+func SearchPartners(lat, lng, rngMiles float64, filters SearchFilters, page, limit int) (PartnerPage, error) {
+	if err := filters.Validate(); err != nil {
+		return PartnerPage{}, err
+	}
+
+	pp, err := findPartners(lat, lng, rngMiles, filters, page, limit)
+	if err != nil {
+		return pp, err
+	}
+
+	if filters.needsDetails() {
+		addAllPlaceDetails(pp.Results)
+	}
+	pp.Results = filters.filter(pp.Results, true)
+	sortResults(pp.Results, filters.Sort)
+
+	return pp, nil
+}
+
+// findPartners pages through the partners near a point, filtered by what's stored about them
+func findPartners(lat, lng, rngMiles float64, filters SearchFilters, page, limit int) (PartnerPage, error) {
+	if page < 1 {
+		page = 1
+	}
+
+	// Ask for one extra restaurant to find out whether there is another page
+	results, err := database.SearchPartners(database.PartnerQuery{
+		Lat:       lat,
+		Lng:       lng,
+		MaxMeters: rngMiles * 1600,
+		Cuisines:  filters.Cuisines,
+		Dietary:   filters.Dietary,
+		Campaign:  filters.HasCampaign,
+		Skip:      (page - 1) * limit,
+		Limit:     limit + 1,
+	})
+	if err != nil {
+		return PartnerPage{}, err
+	}
+
+	pp := PartnerPage{Results: []APIDetails{}, Page: page}
+	if len(results) > limit {
+		results = results[:limit]
+		pp.More = true
+	}
+
+	for _, r := range results {
+		pp.Results = append(pp.Results, partnerDetails(r))
+	}
+
+	return pp, nil
+}
+
+func partnerDetails(r database.PartnerResult) APIDetails {
+	now := time.Now()
+
+	d := APIDetails{
+		Name:        r.Name,
+		Address:     r.Address,
+		Latitude:    r.Location.Lat(),
+		Longitude:   r.Location.Lng(),
+		Description: r.Description,
+		RestID:      r.UUID,
+		Paused:      r.Paused,
+		Cuisines:    r.Cuisines,
+		Dietary:     r.Dietary,
+		Campaign:    r.ActiveCampaign(now) != nil,
+		Distance:    math.Round(r.Distance),
+		placeID:     r.PlaceID,
+	}
+
+	if open, known := r.IsOpenAt(now); known {
+		d.OpenNow = &open
+	}
+
+	return d
+}
+
+// partnerDetailsWorkers caps how many details lookups addAllPlaceDetails has in flight at once
+const partnerDetailsWorkers = 5
+
+// mergePartners adds the partners near a search which Google didn't return to its On results,
+// as long as they match the query. Their rating, price and photo come from Google's (cached)
+// details, so they can be filtered like the rest.
+func mergePartners(sr *SearchResponse, query string, lat, lng, rngMiles float64, filters SearchFilters) {
+	pp, err := findPartners(lat, lng, math.Min(rngMiles, 50000/1600.0), filters, 1, 20)
+	if err != nil {
+		log.Error("BB: unable to search partner restaurants: ", err)
+		return
+	}
+
+	found := map[string]bool{}
+	for _, d := range sr.On {
+		found[d.RestID] = true
+	}
+
+	missing := []APIDetails{}
+	for _, d := range pp.Results {
+		if !found[d.RestID] && matchesQuery(d, query) {
+			missing = append(missing, d)
+		}
+	}
+
+	addAllPlaceDetails(missing)
+	sr.On = append(sr.On, missing...)
+}
+
+// matchesQuery reports whether a partner is what a search query asks for, by its name or one
+// of its cuisines. Every partner matches an empty query, or one like "restaurants" or "food".
+func matchesQuery(d APIDetails, query string) bool {
+	query = strings.ToLower(strings.TrimSpace(query))
+	if strings.Contains(strings.ToLower(d.Name), query) {
+		return true
+	}
+
+	specific := false
+	for _, word := range strings.Fields(query) {
+		if stringInSlice(strings.TrimSuffix(word, "s"), foodTypes) {
+			continue
+		}
+		specific = true
+
+		for _, c := range d.Cuisines {
+			if strings.Contains(c, word) {
+				return true
+			}
+		}
+	}
+	return !specific
+}
+
+// addAllPlaceDetails looks up a list of results in parallel, rather than adding a round trip per result
+func addAllPlaceDetails(list []APIDetails) {
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, partnerDetailsWorkers)
+	for i := range list {
+		wg.Add(1)
+		go func(d *APIDetails) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			addPlaceDetails(d)
+		}(&list[i])
+	}
+	wg.Wait()
+}
+
+// addPlaceDetails fills in what partnerDetails can't know without Google
+func addPlaceDetails(d *APIDetails) {
+	details, err := GetPlaceDetails(d.placeID)
+	if err != nil {
+		return
+	}
+
+	d.Rating = details.Rating
+	d.PriceLevel = details.PriceLevel
+	if len(details.Photos) > 0 {
+		d.Image = details.Photos[0].PhotoReference
+	}
+	if d.OpenNow == nil && details.OpeningHours != nil {
+		d.OpenNow = details.OpeningHours.OpenNow
+	}
+}
+
+// LocateRestaurant looks up where a restaurant's place is and stores it for partner search
+func LocateRestaurant(owner, placeID string) {
+	details, err := GetPlaceDetails(placeID)
+	if err != nil {
+		log.Error("BB: unable to locate restaurant: ", err)
+		return
+	}
+
+	loc := details.Geometry.Location
+	if err := database.SetRestaurantLocation(owner, loc.Lat, loc.Lng); err != nil {
+		log.Error("BB: unable to store restaurant location: ", err)
+	}
+}
+
+// BackfillLocations locates every restaurant which has a place ID but no stored location
+func BackfillLocations() {
+	rests, err := database.GetUnlocatedRestaurants()
+	if err != nil {
+		log.Error(err)
+		return
+	}
+
+	for _, r := range rests {
+		LocateRestaurant(r.Owner, r.PlaceID)
+	}
+}
@@ -0,0 +1,28 @@
+package places
+
+import "testing"
+
+func TestMatchesQuery(t *testing.T) {
+	pizza := APIDetails{Name: "Tony's Slice House", Cuisines: []string{"pizza", "italian"}}
+
+	cases := []struct {
+		query string
+		want  bool
+	}{
+		{"", true},
+		{"restaurants", true},
+		{"food", true},
+		{"slice", true},
+		{"Tony's", true},
+		{"pizza", true},
+		{"italian restaurant", true},
+		{"sushi", false},
+		{"sushi restaurant", false},
+	}
+
+	for _, c := range cases {
+		if got := matchesQuery(pizza, c.query); got != c.want {
+			t.Errorf("%q: expected %v, got %v", c.query, c.want, got)
+		}
+	}
+}
@@ -1,14 +1,12 @@
 package places
 
 import (
-	"encoding/json"
 	"errors"
 	"fmt"
 	"math"
-	"net/http"
+	"net/url"
 	"os"
-	"os/exec"
-	"strings"
+	"strconv"
 	"time"
 
 	"github.com/rishabh-bector/BenevolentBitesBack/database"
@@ -18,101 +16,168 @@ import (
 )
 
 var (
-	GKey string
+	GKey     string
+	Fixtures = "../places/fixtures"
 )
 
-// Initialize creates the Google Maps API client
+// Initialize creates the Places provider. P_PROVIDER picks google (default), osm for
+// OpenStreetMap, fixtures to replay recorded responses from P_FIXTURES, or record to fill them from Google.
+// Google and OpenStreetMap responses are cached in Mongo unless P_CACHE is off.
 func Initialize() {
 	GKey = os.Getenv("G_API")
+	if dir := os.Getenv("P_FIXTURES"); dir != "" {
+		Fixtures = dir
+	}
+
+	switch os.Getenv("P_PROVIDER") {
+	case "fixtures":
+		SetProvider(&FixtureProvider{Dir: Fixtures})
+	case "record":
+		SetProvider(&FixtureProvider{Dir: Fixtures, Record: NewGoogleClient(GKey)})
+	case "osm":
+		osm := NewOSM()
+		if u := os.Getenv("P_NOMINATIM"); u != "" {
+			osm.NominatimURL = u
+		}
+		if u := os.Getenv("P_OVERPASS"); u != "" {
+			osm.OverpassURL = u
+		}
+		if ua := os.Getenv("P_USER_AGENT"); ua != "" {
+			osm.UserAgent = ua
+		}
+		SetProvider(osm)
+	default:
+		SetProvider(NewGoogleClient(GKey))
+	}
+
+	log.Info("BB: using places provider: ", fmt.Sprintf("%T", Provider))
+
+	if _, replay := Provider.(*FixtureProvider); !replay && os.Getenv("P_CACHE") != "off" {
+		prefix := ""
+		if _, osm := Provider.(*OSM); osm {
+			prefix = "osm:"
+		}
+		SetProvider(&CachedProvider{Upstream: Provider, Prefix: prefix})
+	}
+}
+
+// GetCacheStats returns the hit and miss counts of the places cache, if there is one
+func GetCacheStats() (map[string]CacheStats, bool) {
+	c, ok := Provider.(*CachedProvider)
+	if !ok {
+		return nil, false
+	}
+	return c.Stats(), true
 }
 
 // SearchResponse contains the info returned by a restaurant search query.
 // For any given address/radius, both restaurants supported by Benevolent Bites
 // and those that are not supported will be returned.
 type SearchResponse struct {
-	On  []APIDetails `json:"on"`
-	Off []APIDetails `json:"off"`
+	On     []APIDetails `json:"on"`
+	Off    []APIDetails `json:"off"`
+	Cursor string       `json:"cursor,omitempty"` // Pass to /search/coords for the next page, empty on the last one
 }
 
 type APIDetails struct {
-	Name        string  `json:"name"`
-	Address     string  `json:"address"`
-	Latitude    float64 `json:"latitude"`
-	Longitude   float64 `json:"longitude"`
-	Image       string  `json:"image"`
-	Rating      float32 `json:"rating"`
-	PriceLevel  int     `json:"priceLevel"`
-	Description string  `json:"description"`
-	RestID      string  `json:"restID"`
+	Name        string   `json:"name"`
+	Address     string   `json:"address"`
+	Latitude    float64  `json:"latitude"`
+	Longitude   float64  `json:"longitude"`
+	Image       string   `json:"image"`
+	Rating      float32  `json:"rating"`
+	PriceLevel  int      `json:"priceLevel"`
+	Description string   `json:"description"`
+	RestID      string   `json:"restID"`
+	Paused      bool     `json:"paused"`
+	Cuisines    []string `json:"cuisines"`
+	Dietary     []string `json:"dietary"`
+	Campaign    bool     `json:"campaign"`          // Running a campaign right now
+	OpenNow     *bool    `json:"openNow,omitempty"` // Unknown if missing
+	Distance    float64  `json:"distance"`          // Meters from the search origin
+	Favorite    bool     `json:"favorite"`          // One of the signed in user's favorites
+
+	placeID string
 }
 
 // SearchCoords searches for restaurants around the Coords of the origin, based
 // on the query provided by the frontend
-func SearchCoords(query, lat, lng string, rngMiles float64, view string) (SearchResponse, error) {
-	params := map[string]string{
-		"key":      GKey,
-		"location": fmt.Sprintf("%s,%s", lat, lng),
-		"keyword":  query,
+func SearchCoords(query, lat, lng string, rngMiles float64, view string, filters SearchFilters) (SearchResponse, error) {
+	la, err1 := strconv.ParseFloat(lat, 64)
+	ln, err2 := strconv.ParseFloat(lng, 64)
+	if err1 != nil || err2 != nil {
+		return SearchResponse{}, errors.New("sorry bro, invalid coordinates")
+	}
+	if err := filters.Validate(); err != nil {
+		return SearchResponse{}, err
+	}
+
+	params := url.Values{
+		"location": {fmt.Sprintf("%s,%s", lat, lng)},
+		"keyword":  {query},
 	}
 
 	if view == "map" {
-		params["radius"] = fmt.Sprintf("%.1f", math.Min(rngMiles*1600, 50000))
+		params.Set("radius", fmt.Sprintf("%.1f", math.Min(rngMiles*1600, 50000)))
 	} else {
-		params["rankby"] = "distance"
+		params.Set("rankby", "distance")
 	}
+	filters.googleParams(params)
 
-	var res maps.PlacesSearchResponse
-	var tokRes map[string]interface{}
-	body, err := SendGAPIRequest("https://maps.googleapis.com/maps/api/place/nearbysearch/json", params)
+	res, err := Provider.NearbySearch(params)
 	if err != nil {
 		log.Error(err)
 		return SearchResponse{}, err
 	}
-	err = json.Unmarshal(body, &res)
-	err = json.Unmarshal(body, &tokRes)
-	if err != nil {
-		log.Error(err)
-		file, err := os.Create("log.txt")
-		file.Write(body)
-		return SearchResponse{}, err
-	}
-
-	places := res.Results
-
-	// Gather more search results from page tokens
-	depth := 0
-	nToken, ok := tokRes["next_page_token"].(string)
 
-	for depth < -1 {
-		log.Info("Sleeping...")
-		time.Sleep(1 * time.Second)
-		log.Info("Done!")
+	sr := searchResults(res.Results, la, ln, nil)
+	mergePartners(&sr, query, la, ln, rngMiles, filters)
+	filters.apply(&sr)
+	sr.Cursor = newCursor(res.NextPageToken, filters, la, ln, sr.On, nil)
 
-		if !ok || nToken == "" {
-			log.Info("No page token found! (string conversion failure)")
-			break
-		} else {
-			log.Info("Resolving page token: ", nToken)
-		}
+	return sr, nil
+}
 
-		nextRes, nTok, err := ResolvePageToken(params["key"], nToken)
-		if err != nil {
-			log.Error(err)
-		}
+// SearchPage returns the next page of a search, given the cursor returned with the page before it.
+// Partners which were already returned on an earlier page are left out.
+// Results are only sorted within each page.
+func SearchPage(cursor string) (SearchResponse, error) {
+	cur, err := decodeCursor(cursor)
+	if err != nil {
+		return SearchResponse{}, err
+	}
 
-		log.Info("Received next page: ")
+	res, err := resolvePageToken(cur)
+	if err != nil {
+		log.Error(err)
+		return SearchResponse{}, err
+	}
 
-		places = append(places, nextRes.Results...)
+	sr := searchResults(res.Results, cur.Lat, cur.Lng, cur.Seen)
+	cur.Filters.apply(&sr)
+	sr.Cursor = newCursor(res.NextPageToken, cur.Filters, cur.Lat, cur.Lng, sr.On, cur.Seen)
 
-		nToken = nTok
-		depth++
-	}
+	return sr, nil
+}
 
+// searchResults splits Google's results into restaurants on and off Benevolent Bites
+func searchResults(places []maps.PlacesSearchResult, lat, lng float64, seen []string) SearchResponse {
 	sr := SearchResponse{
 		On:  []APIDetails{},
 		Off: []APIDetails{},
 	}
 
+	// Look up every partner on the page at once
+	ids := []string{}
+	for _, p := range places {
+		ids = append(ids, p.PlaceID)
+	}
+	partners, err := database.GetRestaurantsByPlaceIDs(ids)
+	if err != nil {
+		log.Error("BB: unable to look up partner restaurants: ", err)
+	}
+
+	now := time.Now()
 	for p := range places {
 		pid := places[p].PlaceID
 
@@ -120,7 +185,10 @@ func SearchCoords(query, lat, lng string, rngMiles float64, view string) (Search
 			continue
 		}
 
-		r := database.DoesRestaurantExistPlaceID(pid)
+		r, ok := partners[pid]
+		if !ok {
+			r = database.NilRestaurant
+		}
 
 		d := APIDetails{
 			Name:       places[p].Name,
@@ -129,20 +197,37 @@ func SearchCoords(query, lat, lng string, rngMiles float64, view string) (Search
 			Longitude:  places[p].Geometry.Location.Lng,
 			Rating:     places[p].Rating,
 			PriceLevel: places[p].PriceLevel,
+			Distance:   distance(lat, lng, places[p].Geometry.Location.Lat, places[p].Geometry.Location.Lng),
 		}
 
 		if len(places[p].Photos) > 0 {
 			d.Image = places[p].Photos[0].PhotoReference
 		}
+		if places[p].OpeningHours != nil {
+			d.OpenNow = places[p].OpeningHours.OpenNow
+		}
 
 		if r.Owner == "nil" {
 			d.RestID = pid
 
 			sr.Off = append(sr.Off, d)
 		} else {
+			if stringInSlice(r.UUID, seen) {
+				continue
+			}
+
 			d.Description = r.Description
 			d.RestID = r.UUID
 			d.Name = r.Name
+			d.Paused = r.Paused
+			d.Cuisines = r.Cuisines
+			d.Dietary = r.Dietary
+			d.Campaign = r.ActiveCampaign(now) != nil
+
+			// Hours the owner gave us win over Google's
+			if open, known := r.IsOpenAt(now); known {
+				d.OpenNow = &open
+			}
 
 			if r.Published {
 				sr.On = append(sr.On, d)
@@ -150,158 +235,47 @@ func SearchCoords(query, lat, lng string, rngMiles float64, view string) (Search
 		}
 	}
 
-	return sr, nil
+	return sr
 }
 
-func ResolvePageToken(key, tok string) (maps.PlacesSearchResponse, string, error) {
-	params := map[string]string{
-		"key":       key,
-		"pagetoken": tok,
-	}
-
-	var res maps.PlacesSearchResponse
-	var tokRes map[string]interface{}
-	body, err := SendGAPIRequest("https://maps.googleapis.com/maps/api/place/nearbysearch/json", params)
-	if err != nil {
-		log.Error(err)
-		return maps.PlacesSearchResponse{}, "", err
-	}
-	err = json.Unmarshal(body, &res)
-	if err != nil {
-		log.Error(err)
-	}
-	err = json.Unmarshal(body, &tokRes)
-	if err != nil {
-		log.Error(err)
-		file, err := os.Create("log.txt")
-		file.Write(body)
-		return maps.PlacesSearchResponse{}, "", errors.New(err.Error() + " " + string(body))
+// MarkFavorites flags the partners among results which are in a user's favorites
+func MarkFavorites(results []APIDetails, favorites []string) {
+	for i := range results {
+		results[i].Favorite = stringInSlice(results[i].RestID, favorites)
 	}
-
-	nTok, ok := tokRes["next_page_token"].(string)
-	if ok {
-		return res, nTok, nil
-	}
-
-	return res, "", nil
 }
 
-func GetPlacePhoto(pr string) (maps.PlacePhotoResponse, int64, error) {
-	response := maps.PlacePhotoResponse{}
-	res, err := http.Get(fmt.Sprintf("https://maps.googleapis.com/maps/api/place/photo?key=%s&photoreference=%s&maxwidth=%v", GKey, pr, 400))
-	if err != nil {
-		return response, 0, err
-	}
-
-	response.Data = res.Body
-	response.ContentType = res.Header.Get("Content-Type")
-
-	return response, res.ContentLength, nil
+// GetPlacePhoto streams a place photo, scaled down to maxWidth. The caller has to close its Data.
+func GetPlacePhoto(pr string, maxWidth int) (maps.PlacePhotoResponse, int64, error) {
+	return Provider.Photo(pr, maxWidth)
 }
 
 // GetPlaceID uses the Google Places API to search for the "place id"
 // of a particular address
 func GetPlaceID(restName string, address string) (string, error) {
-	params := map[string]string{
-		"key":       GKey,
-		"input":     fmt.Sprintf("%s %s", restName, address),
-		"inputtype": "textquery",
-		"fields":    "place_id",
-	}
-
-	var res maps.FindPlaceFromTextResponse
-	body, err := SendGAPIRequest("https://maps.googleapis.com/maps/api/place/findplacefromtext/json", params)
+	candidates, err := Provider.FindPlace(fmt.Sprintf("%s %s", restName, address), "place_id")
 	if err != nil {
 		return "", err
 	}
-	json.Unmarshal(body, &res)
 
-	if len(res.Candidates) == 0 {
+	if len(candidates) == 0 {
 		return "", errors.New("sorry bro, no restaurant found at that address")
 	}
 
-	return res.Candidates[0].PlaceID, nil
+	return candidates[0].PlaceID, nil
 }
 
 // GetPlaceDetails uses the Google Places API to find details
 // about a particular establishment
 //
 func GetPlaceDetails(placeID string) (maps.PlaceDetailsResult, error) {
-	params := map[string]string{
-		"key":      GKey,
-		"place_id": placeID,
-	}
-
-	var res map[string]interface{}
-	body, err := SendGAPIRequest("https://maps.googleapis.com/maps/api/place/details/json", params)
+	details, err := Provider.Details(placeID)
 	if err != nil {
-		log.Error(err.Error())
+		log.Error("error getting google place details: ", err.Error())
 		return maps.PlaceDetailsResult{}, err
 	}
 
-	err = json.Unmarshal(body, &res)
-	if err != nil {
-		log.Errorf("error unmarshaling google response, %s", err.Error())
-		file, err := os.Create("log.txt")
-		file.Write(body)
-		return maps.PlaceDetailsResult{}, err
-	}
-
-	if _, ok := res["result"]; !ok {
-		return maps.PlaceDetailsResult{}, fmt.Errorf("%s", res)
-	}
-
-	var resMain maps.PlaceDetailsResult
-	body2, err := json.Marshal(res["result"].(map[string]interface{}))
-	if err != nil {
-		log.Errorf("error marshaling, %s", err.Error())
-		return maps.PlaceDetailsResult{}, err
-	}
-
-	err = json.Unmarshal(body2, &resMain)
-	if err != nil {
-		log.Errorf("error unmarshaling google response [result]", err.Error())
-		return maps.PlaceDetailsResult{}, err
-	}
-
-	return resMain, nil
-}
-
-// SendGAPIRequest uses api.py to send a GET to any url, with the given params
-// This is because, for some mysterious reason, it doesn't work in Go
-func SendGAPIRequest(url string, params map[string]string) ([]byte, error) {
-	b, _ := json.Marshal(params)
-	cmd := exec.Command(
-		"python3",
-		"../places/api.py",
-		"--url", url,
-		"--params", string(b),
-	)
-	out, err := cmd.CombinedOutput()
-	if err != nil {
-		return nil, errors.New(err.Error() + " " + string(out))
-	}
-
-	out2 := strings.Replace(string(out), "True", "true", -1)
-	out3 := strings.Replace(string(out2), "False", "false", -1)
-	out4 := strings.Replace(string(out3), "\n", "", -1)
-	out5 := strings.Replace(string(out4), "\\", "", -1)
-
-	onlydouble := []rune(strings.Replace(string(out5), "'", "\"", -1))
-
-	for i := 0; i < len(onlydouble); i++ {
-		if onlydouble[i] == rune('"') {
-			if onlydouble[i+1] != rune(',') && onlydouble[i+1] != rune('}') && onlydouble[i+1] != rune(']') && onlydouble[i+1] != rune(':') {
-				if onlydouble[i-1] != rune('{') && onlydouble[i-1] != rune('[') {
-					if i > 1 && !(onlydouble[i-1] == rune(' ') && (onlydouble[i-2] == rune(':') || onlydouble[i-2] == rune(','))) {
-						onlydouble[i] = '\''
-					}
-				}
-			}
-		}
-	}
-
-	return []byte(string(onlydouble)), nil
+	return details, nil
 }
 
 var foodTypes = []string{
@@ -0,0 +1,198 @@
+package places
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"googlemaps.github.io/maps"
+)
+
+// PlacesProvider is anything that can answer the Places queries we make.
+// The Google client is used in production, FixtureProvider replays recorded responses.
+type PlacesProvider interface {
+	NearbySearch(params url.Values) (NearbyPage, error)
+	FindPlace(input string, fields string) ([]maps.PlacesSearchResult, error)
+	Details(placeID string) (maps.PlaceDetailsResult, error)
+	Geocode(address string) ([]maps.GeocodingResult, error)
+	Autocomplete(params url.Values) ([]maps.AutocompletePrediction, error)
+	Photo(ref string, maxWidth int) (maps.PlacePhotoResponse, int64, error)
+}
+
+// Provider answers every Places query in this package
+var Provider PlacesProvider
+
+// SetProvider replaces the provider used by this package, returning the previous one
+func SetProvider(p PlacesProvider) PlacesProvider {
+	old := Provider
+	Provider = p
+	return old
+}
+
+// ErrNoFixture is returned by FixtureProvider when no response was recorded for a query
+var ErrNoFixture = errors.New("places: no recorded fixture for this query")
+
+// FixtureProvider replays responses recorded as JSON files in Dir.
+// If Record is set, queries without a fixture are sent to it and the answer is saved.
+type FixtureProvider struct {
+	Dir    string
+	Record PlacesProvider
+}
+
+// NearbySearch replays a recorded nearby search
+func (f *FixtureProvider) NearbySearch(params url.Values) (NearbyPage, error) {
+	var page NearbyPage
+	err := f.replay("nearbysearch", params.Encode(), &page, func() (interface{}, error) {
+		return f.Record.NearbySearch(params)
+	})
+	return page, err
+}
+
+// FindPlace replays a recorded findplacefromtext query
+func (f *FixtureProvider) FindPlace(input string, fields string) ([]maps.PlacesSearchResult, error) {
+	var candidates []maps.PlacesSearchResult
+	err := f.replay("findplace", input+"|"+fields, &candidates, func() (interface{}, error) {
+		return f.Record.FindPlace(input, fields)
+	})
+	return candidates, err
+}
+
+// Details replays recorded place details
+func (f *FixtureProvider) Details(placeID string) (maps.PlaceDetailsResult, error) {
+	var details maps.PlaceDetailsResult
+	err := f.replay("details", placeID, &details, func() (interface{}, error) {
+		return f.Record.Details(placeID)
+	})
+	return details, err
+}
+
+// Geocode replays a recorded geocoding query
+func (f *FixtureProvider) Geocode(address string) ([]maps.GeocodingResult, error) {
+	var results []maps.GeocodingResult
+	err := f.replay("geocode", address, &results, func() (interface{}, error) {
+		return f.Record.Geocode(address)
+	})
+	return results, err
+}
+
+// Autocomplete replays recorded predictions. Session tokens differ on every recording, so they aren't part of the key.
+func (f *FixtureProvider) Autocomplete(params url.Values) ([]maps.AutocompletePrediction, error) {
+	key := url.Values{}
+	for k, v := range params {
+		if k != "sessiontoken" {
+			key[k] = v
+		}
+	}
+
+	var predictions []maps.AutocompletePrediction
+	err := f.replay("autocomplete", key.Encode(), &predictions, func() (interface{}, error) {
+		return f.Record.Autocomplete(params)
+	})
+	return predictions, err
+}
+
+// Photo replays a recorded photo. Photos are stored as raw image files.
+func (f *FixtureProvider) Photo(ref string, maxWidth int) (maps.PlacePhotoResponse, int64, error) {
+	path := f.path("photo", ref+"|"+strconv.Itoa(maxWidth), ".img")
+
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) && f.Record != nil {
+		data, err = f.recordPhoto(path, ref, maxWidth)
+	} else if os.IsNotExist(err) {
+		return maps.PlacePhotoResponse{}, 0, ErrNoFixture
+	}
+	if err != nil {
+		return maps.PlacePhotoResponse{}, 0, err
+	}
+
+	return maps.PlacePhotoResponse{
+		ContentType: http.DetectContentType(data),
+		Data:        ioutil.NopCloser(bytes.NewReader(data)),
+	}, int64(len(data)), nil
+}
+
+func (f *FixtureProvider) recordPhoto(path, ref string, maxWidth int) ([]byte, error) {
+	res, _, err := f.Record.Photo(ref, maxWidth)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Data.Close()
+
+	data, err := ioutil.ReadAll(res.Data)
+	if err != nil {
+		return nil, err
+	}
+
+	return data, f.save(path, data)
+}
+
+// replay decodes the fixture for a query into out, recording it first if needed.
+// Errors from the recorded provider are saved too, so they replay the same way.
+func (f *FixtureProvider) replay(kind, key string, out interface{}, record func() (interface{}, error)) error {
+	path := f.path(kind, key, ".json")
+
+	var fixture struct {
+		Response json.RawMessage `json:"response,omitempty"`
+		Error    *StatusError    `json:"error,omitempty"`
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) && f.Record != nil {
+		data, err = f.record(path, record)
+	} else if os.IsNotExist(err) {
+		return ErrNoFixture
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal(data, &fixture); err != nil {
+		return fmt.Errorf("places: invalid fixture %s: %s", path, err.Error())
+	}
+	if fixture.Error != nil {
+		return fixture.Error
+	}
+
+	return json.Unmarshal(fixture.Response, out)
+}
+
+func (f *FixtureProvider) record(path string, record func() (interface{}, error)) ([]byte, error) {
+	res, err := record()
+
+	fixture := map[string]interface{}{"response": res}
+	var se *StatusError
+	if errors.As(err, &se) {
+		fixture = map[string]interface{}{"error": se}
+	} else if err != nil {
+		return nil, err
+	}
+
+	data, err := json.MarshalIndent(fixture, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	return data, f.save(path, data)
+}
+
+func (f *FixtureProvider) save(path string, data []byte) error {
+	if err := os.MkdirAll(f.Dir, 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0644)
+}
+
+// path names a fixture after its kind and a hash of the query, e.g. details-1a2b3c4d5e6f.json
+func (f *FixtureProvider) path(kind, key, ext string) string {
+	sum := sha1.Sum([]byte(key))
+	return filepath.Join(f.Dir, kind+"-"+hex.EncodeToString(sum[:6])+ext)
+}
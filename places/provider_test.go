@@ -0,0 +1,184 @@
+package places
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"googlemaps.github.io/maps"
+)
+
+// withFixtures replays the fixtures committed in places/fixtures, until the returned func is called
+func withFixtures() func() {
+	old := SetProvider(&FixtureProvider{Dir: "fixtures"})
+	return func() { SetProvider(old) }
+}
+
+func TestFixturePlaceLookups(t *testing.T) {
+	defer withFixtures()()
+
+	id, err := GetPlaceID("Taqueria El Sol", "1100 E 6th St, Austin, TX")
+	if err != nil || id != "ChIJfixtureTaqueriaElSol" {
+		t.Fatalf("expected the taqueria's place ID, got %q, %v", id, err)
+	}
+
+	details, err := GetPlaceDetails(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if details.Name != "Taqueria El Sol" || details.InternationalPhoneNumber != "+1 512-555-0142" {
+		t.Errorf("unexpected details %+v", details)
+	}
+	if len(details.Photos) != 1 {
+		t.Fatalf("expected 1 photo, got %d", len(details.Photos))
+	}
+
+	photo, size, err := GetPlacePhoto(details.Photos[0].PhotoReference, 400)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer photo.Data.Close()
+	data, _ := ioutil.ReadAll(photo.Data)
+	if photo.ContentType != "image/png" || int64(len(data)) != size {
+		t.Errorf("unexpected photo %s of %d bytes", photo.ContentType, size)
+	}
+}
+
+func TestFixtureErrorsReplay(t *testing.T) {
+	defer withFixtures()()
+
+	if _, err := GetPlaceDetails("ChIJfixtureClosedForGood"); !IsStatus(err, StatusNotFound) {
+		t.Errorf("expected NOT_FOUND, got %v", err)
+	}
+
+	if _, err := GetPlaceDetails("ChIJneverRecorded"); err != ErrNoFixture {
+		t.Errorf("expected ErrNoFixture, got %v", err)
+	}
+}
+
+// TestFixtureGeocodeNothingFound records a geocode Google finds nothing for through the real
+// client, and checks it's the fixture which was committed for it
+func TestFixtureGeocodeNothingFound(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"results": [], "status": "ZERO_RESULTS"}`))
+	}))
+	defer srv.Close()
+
+	dir, err := ioutil.TempDir("", "fixtures")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	google := NewGoogleClient("test-key")
+	google.BaseURL = srv.URL
+	recorder := &FixtureProvider{Dir: dir, Record: google}
+
+	// ZERO_RESULTS isn't an error for a geocode, just an empty list
+	results, err := recorder.Geocode("nowhere at all")
+	if err != nil || len(results) != 0 {
+		t.Fatalf("expected no results, got %v, %v", results, err)
+	}
+
+	name := filepath.Base(recorder.path("geocode", "nowhere at all", ".json"))
+	recorded, err := ioutil.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	committed, err := ioutil.ReadFile(filepath.Join("fixtures", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(recorded) != string(committed) {
+		t.Errorf("fixtures/%s isn't what the client records:\n%s", name, recorded)
+	}
+
+	defer withFixtures()()
+	if _, err := Geocode("nowhere at all"); err == nil || err.Error() != "sorry bro, unable to find that address" {
+		t.Errorf("expected the address not to be found, got %v", err)
+	}
+}
+
+func TestFixtureGeocode(t *testing.T) {
+	defer withFixtures()()
+
+	place, err := Geocode(" 78702 ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if place.Geometry.Location.Lat != 30.2638 || place.Geometry.Viewport.NorthEast.Lat != 30.2867 {
+		t.Errorf("unexpected geometry %+v", place.Geometry)
+	}
+}
+
+func TestFixtureNearbySearch(t *testing.T) {
+	defer withFixtures()()
+
+	params := url.Values{
+		"location": {"30.263,-97.731"},
+		"keyword":  {"tacos"},
+		"rankby":   {"distance"},
+	}
+	page, err := Provider.NearbySearch(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Results) != 3 || page.NextPageToken != "fixture-next-page" {
+		t.Fatalf("unexpected page of %d results, token %q", len(page.Results), page.NextPageToken)
+	}
+
+	food := []maps.PlacesSearchResult{}
+	for _, p := range page.Results {
+		if isFoodPlace(p.Types) {
+			food = append(food, p)
+		}
+	}
+	if len(food) != 2 {
+		t.Errorf("expected the bank to be left out, got %d food places", len(food))
+	}
+}
+
+// stubProvider answers details for a single place, counting how often it's asked
+type stubProvider struct {
+	PlacesProvider
+	calls int
+}
+
+func (s *stubProvider) Details(placeID string) (maps.PlaceDetailsResult, error) {
+	s.calls++
+	if placeID != "ChIJstub" {
+		return maps.PlaceDetailsResult{}, &StatusError{Status: StatusNotFound}
+	}
+	return maps.PlaceDetailsResult{PlaceID: placeID, Name: "Stub Diner"}, nil
+}
+
+func TestFixtureRecording(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fixtures")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	stub := &stubProvider{}
+	recorder := &FixtureProvider{Dir: dir, Record: stub}
+	replayer := &FixtureProvider{Dir: dir}
+
+	for _, p := range []*FixtureProvider{recorder, recorder, replayer} {
+		d, err := p.Details("ChIJstub")
+		if err != nil || d.Name != "Stub Diner" {
+			t.Fatalf("expected the stub diner, got %+v, %v", d, err)
+		}
+		if _, err := p.Details("ChIJmissing"); !IsStatus(err, StatusNotFound) {
+			t.Fatalf("expected a recorded NOT_FOUND, got %v", err)
+		}
+	}
+
+	if stub.calls != 2 {
+		t.Errorf("expected each query to be recorded once, upstream was asked %d times", stub.calls)
+	}
+}
@@ -0,0 +1,132 @@
+package twilio
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rishabh-bector/BenevolentBitesBack/crypto"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// APIError is returned when Twilio answers with an error status
+type APIError struct {
+	Status   int    `json:"status"`
+	Code     int    `json:"code"`
+	Message  string `json:"message"`
+	MoreInfo string `json:"more_info"`
+}
+
+func (e *APIError) Error() string {
+	if e.Message != "" {
+		return fmt.Sprintf("twilio: %d: %s", e.Status, e.Message)
+	}
+	return fmt.Sprintf("twilio: %d", e.Status)
+}
+
+// Twilio sends codes through the Twilio REST API
+type Twilio struct {
+	SID     string // Account SID
+	Token   string // Auth token, also used to check callback signatures
+	Number  string // Number calls and texts come from
+	BaseURL string
+	Client  *resty.Client
+}
+
+// NewTwilio creates a client for an account, with a sensible timeout
+func NewTwilio(sid, token, number string) *Twilio {
+	client := resty.New().
+		SetBasicAuth(sid, token).
+		SetTimeout(15 * time.Second)
+
+	return &Twilio{
+		SID:     sid,
+		Token:   token,
+		Number:  number,
+		BaseURL: "https://api.twilio.com/2010-04-01",
+		Client:  client,
+	}
+}
+
+// Send places a call which reads out the code, or texts it
+func (t *Twilio) Send(to, code, method, lang string) (Delivery, error) {
+	form := map[string]string{
+		"To":   to,
+		"From": t.Number,
+	}
+	if CallbackURL != "" {
+		form["StatusCallback"] = CallbackURL + "/twilio/status"
+	}
+
+	switch method {
+	case MethodSMS:
+		_, l := GetLanguage(lang)
+		form["Body"] = fmt.Sprintf(l.SMS, code)
+		return t.post("Messages.json", form)
+	case MethodVoice:
+		token, err := crypto.EncryptString(code)
+		if err != nil {
+			return Delivery{}, err
+		}
+		form["Twiml"] = VoiceTwiML(code, lang, MenuURL(token))
+		return t.post("Calls.json", form)
+	default:
+		return Delivery{}, errors.New("sorry bro, codes can only be sent by sms or voice")
+	}
+}
+
+// ValidateSignature checks that a callback came from Twilio. fullURL is the URL Twilio
+// requested, including the query, and params the posted form.
+func (t *Twilio) ValidateSignature(fullURL string, params url.Values, signature string) bool {
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var sb strings.Builder
+	sb.WriteString(fullURL)
+	for _, k := range keys {
+		for _, v := range params[k] {
+			sb.WriteString(k)
+			sb.WriteString(v)
+		}
+	}
+
+	mac := hmac.New(sha1.New, []byte(t.Token))
+	mac.Write([]byte(sb.String()))
+	expected := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+
+	return hmac.Equal([]byte(expected), []byte(signature))
+}
+
+// post creates a call or message, turning error responses into an APIError
+func (t *Twilio) post(resource string, form map[string]string) (Delivery, error) {
+	var d Delivery
+	apiErr := &APIError{}
+
+	res, err := t.Client.R().
+		SetFormData(form).
+		SetResult(&d).
+		SetError(apiErr).
+		Post(fmt.Sprintf("%s/Accounts/%s/%s", t.BaseURL, t.SID, resource))
+	if err != nil {
+		return Delivery{}, err
+	}
+
+	if res.IsError() {
+		if apiErr.Status == 0 {
+			apiErr.Status = res.StatusCode()
+		}
+		return Delivery{}, apiErr
+	}
+
+	return d, nil
+}
@@ -0,0 +1,105 @@
+package twilio
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/rishabh-bector/BenevolentBitesBack/database"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// VerificationProvider is anything that can get a verification code to a phone number.
+// Twilio is used in production, FakeProvider keeps codes in memory instead.
+type VerificationProvider interface {
+	// Send calls or texts a code to a number. The method is MethodVoice or MethodSMS,
+	// and lang one of Languages.
+	Send(to, code, method, lang string) (Delivery, error)
+}
+
+// Delivery identifies a call or message, so its status callbacks can be matched up with it
+type Delivery struct {
+	Sid    string `json:"sid"`
+	Status string `json:"status"`
+}
+
+// Provider sends every verification code in this package
+var Provider VerificationProvider
+
+// SetProvider replaces the provider used by this package, returning the previous one
+func SetProvider(p VerificationProvider) VerificationProvider {
+	old := Provider
+	Provider = p
+	return old
+}
+
+// SessionStore keeps the codes which were sent until they're used, see database.StartVerification
+type SessionStore interface {
+	Start(email, phone, code, method, sid, status string) error
+	Check(email, code string) error
+}
+
+// Sessions are kept in Mongo, tests swap in their own store
+var Sessions SessionStore = mongoSessions{}
+
+type mongoSessions struct{}
+
+func (mongoSessions) Start(email, phone, code, method, sid, status string) error {
+	return database.StartVerification(email, phone, code, method, sid, status)
+}
+
+func (mongoSessions) Check(email, code string) error {
+	return database.CheckVerification(email, code)
+}
+
+// FakeMessage is a code FakeProvider pretended to send
+type FakeMessage struct {
+	To     string
+	Code   string
+	Method string
+	Lang   string
+	Sid    string
+}
+
+// FakeProvider captures codes instead of sending them, so restaurants can be verified
+// locally and in tests without a network or a Twilio account
+type FakeProvider struct {
+	mu   sync.Mutex
+	Sent []FakeMessage
+}
+
+// Send records the code and reports it as delivered
+func (f *FakeProvider) Send(to, code, method, lang string) (Delivery, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	msg := FakeMessage{
+		To:     to,
+		Code:   code,
+		Method: method,
+		Lang:   lang,
+		Sid:    fmt.Sprintf("fake-%d", len(f.Sent)+1),
+	}
+	f.Sent = append(f.Sent, msg)
+
+	log.Info("BB: fake verification code for ", to, ": ", code)
+
+	status := "completed"
+	if method == MethodSMS {
+		status = "delivered"
+	}
+	return Delivery{Sid: msg.Sid, Status: status}, nil
+}
+
+// LastCode returns the latest code sent to a number
+func (f *FakeProvider) LastCode(to string) (string, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for i := len(f.Sent) - 1; i >= 0; i-- {
+		if f.Sent[i].To == to {
+			return f.Sent[i].Code, true
+		}
+	}
+	return "", false
+}
@@ -1,63 +1,120 @@
 package twilio
 
 import (
-	"fmt"
-	"math/rand"
+	"crypto/rand"
+	"errors"
+	"math/big"
+	"net/url"
 	"os"
+	"strings"
 
-	"github.com/go-resty/resty/v2"
+	"github.com/rishabh-bector/BenevolentBitesBack/crypto"
+
+	log "github.com/sirupsen/logrus"
 )
 
-// Codes stores all of the currently issued codes in RAM
-// map[email]code
-var Codes map[string]string
+// CodeLength is the number of digits in a verification code
+const CodeLength = 4
 
-var (
-	TwilioNumber string
-	RestyClient  *resty.Client
+// Ways of sending a verification code
+const (
+	MethodVoice = "voice"
+	MethodSMS   = "sms"
 )
 
+// CallbackURL is the public URL of this server, for Twilio's callbacks. Optional.
+var CallbackURL string
+
+// Initialize creates the verification provider. TW_PROVIDER picks twilio (default), which
+// reads its account from TW_SID, TW_TKN, TW_NUM and optionally TW_URL, or fake to log
+// codes instead of sending them. Without a Twilio account the server won't start,
+// except locally, where it falls back to the fake.
 func Initialize() {
-	TwilioNumber = os.Getenv("TW_NUM")
-	RestyClient = resty.New()
-	RestyClient.SetBasicAuth("AC8755a2368f6e69bd997a1c0d2e5f40e3", os.Getenv("TW_TKN"))
-	Codes = make(map[string]string)
+	CallbackURL = strings.TrimRight(os.Getenv("TW_CALLBACK"), "/")
+
+	switch os.Getenv("TW_PROVIDER") {
+	case "fake":
+		if os.Getenv("S_ENV") != "LOCAL" {
+			log.Warn("BB: verification codes are not being sent, TW_PROVIDER is fake")
+		}
+		SetProvider(&FakeProvider{})
+	default:
+		t := NewTwilio(os.Getenv("TW_SID"), os.Getenv("TW_TKN"), os.Getenv("TW_NUM"))
+		if u := os.Getenv("TW_URL"); u != "" {
+			t.BaseURL = strings.TrimRight(u, "/")
+		}
+
+		if t.SID == "" || t.Token == "" || t.Number == "" {
+			if os.Getenv("S_ENV") != "LOCAL" {
+				log.Fatal("BB: TW_SID (the Twilio account SID), TW_TKN and TW_NUM are needed to send verification codes")
+			}
+			log.Warn("BB: no Twilio account configured, logging verification codes instead")
+			SetProvider(&FakeProvider{})
+			return
+		}
+
+		SetProvider(t)
+	}
 }
 
-// MakeConfirmationCall calls a restaurant's phone number to verify them
-func MakeConfirmationCall(recipient string, email string) error {
-	code := generateConfirmationCode()
-	_, err := RestyClient.R().SetFormData(
-		map[string]string{
-			"To":    recipient,
-			"From":  TwilioNumber,
-			"Twiml": fmt.Sprintf("<Response><Say>Hello! Your restaurant verification code is %s</Say></Response>", code)}).
-		Post("https://api.twilio.com/2010-04-01/Accounts/AC8755a2368f6e69bd997a1c0d2e5f40e3/Calls.json")
+// SendConfirmationCode calls or texts a restaurant's phone number to verify them.
+// The method is MethodVoice or MethodSMS, and lang one of Languages.
+func SendConfirmationCode(recipient, email, method, lang string) error {
+	if method != MethodVoice && method != MethodSMS {
+		return errors.New("sorry bro, codes can only be sent by sms or voice")
+	}
 
+	code, err := generateConfirmationCode()
 	if err != nil {
 		return err
 	}
 
-	Codes[email] = code
+	d, err := Provider.Send(recipient, code, method, lang)
+	if err != nil {
+		return err
+	}
 
-	return nil
+	return Sessions.Start(email, recipient, code, method, d.Sid, d.Status)
 }
 
-func VerifyCode(email string, code string) bool {
-	if c, ok := Codes[email]; ok {
-		if code == c {
-			return true
-		}
+// MenuTwiML answers the language menu at the end of a verification call, reading the code
+// again in the language that was picked
+func MenuTwiML(token, digits string) (string, error) {
+	code, err := crypto.DecryptString(token)
+	if err != nil {
+		return "", err
 	}
-	return false
+
+	lang, ok := LanguageForDigit(digits)
+	if !ok {
+		lang = "en"
+	}
+
+	return VoiceTwiML(code, lang, MenuURL(token)), nil
 }
 
-// 4 digit random pin
-func generateConfirmationCode() string {
-	return fmt.Sprintf("%s %s %s %s",
-		rand.Intn(10),
-		rand.Intn(10),
-		rand.Intn(10),
-		rand.Intn(10),
-	)
+// VerifyCode checks a code the owner entered against the last one sent to them
+func VerifyCode(email string, code string) error {
+	code = strings.Join(strings.Fields(code), "")
+	return Sessions.Check(email, code)
+}
+
+// ValidateSignature checks that a callback came from Twilio. Only the Twilio provider
+// gets callbacks, so it's always false with any other.
+func ValidateSignature(fullURL string, params url.Values, signature string) bool {
+	t, ok := Provider.(*Twilio)
+	return ok && t.ValidateSignature(fullURL, params, signature)
+}
+
+// generateConfirmationCode returns a random pin of CodeLength digits
+func generateConfirmationCode() (string, error) {
+	var sb strings.Builder
+	for i := 0; i < CodeLength; i++ {
+		d, err := rand.Int(rand.Reader, big.NewInt(10))
+		if err != nil {
+			return "", err
+		}
+		sb.WriteString(d.String())
+	}
+	return sb.String(), nil
 }
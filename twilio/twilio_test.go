@@ -0,0 +1,191 @@
+package twilio
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rishabh-bector/BenevolentBitesBack/database"
+)
+
+// memSessions keeps verification sessions in memory, so tests don't need Mongo.
+// Sessions follow the same rules as in Mongo, see database.Verification.Check.
+type memSessions struct {
+	sessions map[string]*database.Verification
+}
+
+func (m *memSessions) Start(email, phone, code, method, sid, status string) error {
+	v := database.NewVerification(email, phone, code, method, sid, status, time.Now())
+	m.sessions[email] = &v
+	return nil
+}
+
+func (m *memSessions) Check(email, code string) error {
+	v, ok := m.sessions[email]
+	if !ok {
+		return database.ErrNoVerification
+	}
+	return v.Check(code, time.Now())
+}
+
+// withFake swaps in the fake provider and in memory sessions, until the returned func is called
+func withFake() (*FakeProvider, func()) {
+	fake := &FakeProvider{}
+	oldProvider := SetProvider(fake)
+	oldSessions := Sessions
+	Sessions = &memSessions{sessions: map[string]*database.Verification{}}
+
+	return fake, func() {
+		SetProvider(oldProvider)
+		Sessions = oldSessions
+	}
+}
+
+func TestVerifyWithFakeProvider(t *testing.T) {
+	for _, method := range []string{MethodSMS, MethodVoice} {
+		t.Run(method, func(t *testing.T) {
+			testVerifyWithFakeProvider(t, method)
+		})
+	}
+}
+
+func testVerifyWithFakeProvider(t *testing.T, method string) {
+	fake, restore := withFake()
+	defer restore()
+
+	err := SendConfirmationCode("+15555550100", "owner@example.com", method, "es")
+	if err != nil {
+		t.Fatalf("%s: sending code: %v", method, err)
+	}
+
+	code, ok := fake.LastCode("+15555550100")
+	if !ok || len(code) != CodeLength {
+		t.Fatalf("%s: expected a %d digit code, got %q", method, CodeLength, code)
+	}
+
+	if err := VerifyCode("owner@example.com", "0000"+code); err != database.ErrWrongCode {
+		t.Errorf("%s: expected a wrong code, got %v", method, err)
+	}
+
+	// Owners sometimes type the code the way it was read out
+	spaced := code[:2] + " " + code[2:]
+	if err := VerifyCode("owner@example.com", spaced); err != nil {
+		t.Errorf("%s: verifying code: %v", method, err)
+	}
+
+	if err := VerifyCode("owner@example.com", code); err == nil {
+		t.Errorf("%s: a code worked twice", method)
+	}
+}
+
+func TestSendRejectsUnknownMethod(t *testing.T) {
+	fake, restore := withFake()
+	defer restore()
+
+	if err := SendConfirmationCode("+15555550100", "owner@example.com", "fax", "en"); err == nil {
+		t.Fatal("expected an error for an unknown method")
+	}
+	if len(fake.Sent) != 0 {
+		t.Fatalf("expected nothing sent, got %d", len(fake.Sent))
+	}
+}
+
+func TestTwilioErrorStatus(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path != "/Accounts/ACtest/Messages.json" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		if r.FormValue("To") == "+15555550199" {
+			w.WriteHeader(400)
+			w.Write([]byte(`{"code": 21211, "message": "The 'To' number is not a valid phone number.", "status": 400}`))
+			return
+		}
+		w.WriteHeader(201)
+		w.Write([]byte(`{"sid": "SM123", "status": "queued"}`))
+	}))
+	defer srv.Close()
+
+	tw := NewTwilio("ACtest", "token", "+15555550000")
+	tw.BaseURL = srv.URL
+
+	d, err := tw.Send("+15555550100", "1234", MethodSMS, "en")
+	if err != nil || d.Sid != "SM123" || d.Status != "queued" {
+		t.Fatalf("expected a queued message, got %+v, %v", d, err)
+	}
+
+	_, err = tw.Send("+15555550199", "1234", MethodSMS, "en")
+	apiErr, ok := err.(*APIError)
+	if !ok || apiErr.Status != 400 || apiErr.Code != 21211 {
+		t.Fatalf("expected a 400 APIError, got %v", err)
+	}
+}
+
+func TestValidateSignature(t *testing.T) {
+	// The example from Twilio's security docs
+	tw := NewTwilio("ACtest", "12345", "+15555550000")
+	params := url.Values{
+		"CallSid": {"CA1234567890ABCDE"},
+		"Caller":  {"+12349013030"},
+		"Digits":  {"1234"},
+		"From":    {"+12349013030"},
+		"To":      {"+18005551212"},
+	}
+
+	if !tw.ValidateSignature("https://mycompany.com/myapp.php?foo=1&bar=2", params, "0/KCTR6DLpKmkAf8muzZqo1nDgQ=") {
+		t.Error("expected the signature to be valid")
+	}
+	if tw.ValidateSignature("https://mycompany.com/myapp.php?foo=1&bar=3", params, "0/KCTR6DLpKmkAf8muzZqo1nDgQ=") {
+		t.Error("expected a changed URL to fail")
+	}
+}
+
+func TestMenuReadsCodeAgain(t *testing.T) {
+	var twiml string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		twiml = r.FormValue("Twiml")
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(201)
+		w.Write([]byte(`{"sid": "CA123", "status": "queued"}`))
+	}))
+	defer srv.Close()
+
+	oldCallback := CallbackURL
+	CallbackURL = "https://bb.example.com"
+	defer func() { CallbackURL = oldCallback }()
+
+	tw := NewTwilio("ACtest", "token", "+15555550000")
+	tw.BaseURL = srv.URL
+	if _, err := tw.Send("+15555550100", "4821", MethodVoice, "en"); err != nil {
+		t.Fatalf("sending code: %v", err)
+	}
+
+	m := regexp.MustCompile(`action="([^"]+)"`).FindStringSubmatch(twiml)
+	if m == nil {
+		t.Fatalf("expected a language menu, got %s", twiml)
+	}
+	menu, err := url.Parse(strings.Replace(m[1], "&amp;", "&", -1))
+	if err != nil {
+		t.Fatalf("parsing menu URL: %v", err)
+	}
+	token := menu.Query().Get("t")
+
+	again, err := MenuTwiML(token, "1")
+	if err != nil {
+		t.Fatalf("answering menu: %v", err)
+	}
+	lang, _ := LanguageForDigit("1")
+	if want := VoiceTwiML("4821", lang, MenuURL(token)); again != want {
+		t.Errorf("expected the code to be read again, got %s", again)
+	}
+
+	changed := []byte(token)
+	changed[len(changed)/2] ^= 1
+	if _, err := MenuTwiML(string(changed), "1"); err == nil {
+		t.Error("expected a changed token to be rejected")
+	}
+}
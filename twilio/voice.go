@@ -0,0 +1,128 @@
+package twilio
+
+import (
+	"fmt"
+	"html"
+	"net/url"
+	"strings"
+)
+
+// Language is everything a verification call or text says, in one language
+type Language struct {
+	Code  string // Twilio's language code for <Say>
+	Intro string
+	Again string
+	Bye   string
+	Offer string // How to hear the code in this language instead, %d is the key to press
+	SMS   string // %s is the code
+}
+
+// Languages a verification code can be sent in. The order is the order of the language
+// menu at the end of a call, so the first one is read after pressing 1.
+var (
+	LanguageOrder = []string{"en", "es", "fr", "zh"}
+	Languages     = map[string]Language{
+		"en": {
+			Code:  "en-US",
+			Intro: "Hello! This is Benevolent Bites. Your restaurant verification code is",
+			Again: "Once again, your code is",
+			Bye:   "Thank you, goodbye!",
+			Offer: "For English, press %d.",
+			SMS:   "Your Benevolent Bites verification code is %s. It expires in 10 minutes.",
+		},
+		"es": {
+			Code:  "es-MX",
+			Intro: "¡Hola! Le llamamos de Benevolent Bites. Su código de verificación es",
+			Again: "Una vez más, su código es",
+			Bye:   "¡Gracias, adiós!",
+			Offer: "Para español, oprima %d.",
+			SMS:   "Su código de verificación de Benevolent Bites es %s. Vence en 10 minutos.",
+		},
+		"fr": {
+			Code:  "fr-FR",
+			Intro: "Bonjour ! Ici Benevolent Bites. Votre code de vérification est",
+			Again: "Encore une fois, votre code est",
+			Bye:   "Merci, au revoir !",
+			Offer: "Pour le français, appuyez sur %d.",
+			SMS:   "Votre code de vérification Benevolent Bites est %s. Il expire dans 10 minutes.",
+		},
+		"zh": {
+			Code:  "zh-CN",
+			Intro: "您好！这里是 Benevolent Bites。您的餐厅验证码是",
+			Again: "再说一遍，您的验证码是",
+			Bye:   "谢谢，再见！",
+			Offer: "中文请按 %d。",
+			SMS:   "您的 Benevolent Bites 验证码是 %s，10 分钟内有效。",
+		},
+	}
+)
+
+// CodeRepeats is how many times a call reads out the code
+const CodeRepeats = 3
+
+// GetLanguage returns the language for a short code like "es", falling back to English
+func GetLanguage(lang string) (string, Language) {
+	if l, ok := Languages[lang]; ok {
+		return lang, l
+	}
+	return "en", Languages["en"]
+}
+
+// LanguageForDigit returns the language picked from the menu at the end of a call
+func LanguageForDigit(digits string) (string, bool) {
+	for i, lang := range LanguageOrder {
+		if digits == fmt.Sprint(i+1) {
+			return lang, true
+		}
+	}
+	return "", false
+}
+
+// VoiceTwiML reads a code out slowly, one digit at a time, CodeRepeats times. If menuURL
+// is set, callers can then press a key to hear it again in another language, which
+// Twilio posts to menuURL.
+func VoiceTwiML(code, lang, menuURL string) string {
+	lang, l := GetLanguage(lang)
+
+	var sb strings.Builder
+	sb.WriteString("<Response>")
+	for i := 0; i < CodeRepeats; i++ {
+		line := l.Intro
+		if i > 0 {
+			line = l.Again
+		}
+		sb.WriteString(say(l.Code, line))
+		sb.WriteString(`<Pause length="1"/>`)
+		for _, d := range code {
+			sb.WriteString(say(l.Code, string(d)))
+			sb.WriteString(`<Pause length="1"/>`)
+		}
+		sb.WriteString(`<Pause length="1"/>`)
+	}
+
+	if menuURL != "" {
+		sb.WriteString(fmt.Sprintf(`<Gather numDigits="1" timeout="5" action="%s">`, html.EscapeString(menuURL)))
+		for i, other := range LanguageOrder {
+			sb.WriteString(say(Languages[other].Code, fmt.Sprintf(Languages[other].Offer, i+1)))
+		}
+		sb.WriteString("</Gather>")
+	}
+
+	sb.WriteString(say(l.Code, l.Bye))
+	sb.WriteString("</Response>")
+
+	return sb.String()
+}
+
+// MenuURL is where the language menu of a call posts to. The token carries the code, since
+// only its hash is stored. URLs end up in logs, so the code is encrypted, not just signed.
+func MenuURL(token string) string {
+	if CallbackURL == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s/twilio/voice?%s", CallbackURL, url.Values{"t": {token}}.Encode())
+}
+
+func say(lang, text string) string {
+	return fmt.Sprintf(`<Say language="%s">%s</Say>`, lang, html.EscapeString(text))
+}